@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	duration "github.com/channelmeter/iso8601duration"
+)
+
+// What happens to the announcement of an occurrence which is cancelled after
+// it was sent.
+const (
+	cancelDelete = "delete"
+	cancelNotice = "notice"
+)
+
+// announcedJobs returns the jobs whose message announces an occurrence of
+// event which has not ended yet, and can still be edited.
+func (app *application) announcedJobs(event data.Event) ([]data.Job, error) {
+	since := time.Now()
+
+	d, err := duration.FromString(event.Duration)
+	if err == nil {
+		since = since.Add(-d.ToDuration())
+	}
+
+	return app.models.Jobs.GetAnnouncedForEvent(event.ID, since)
+}
+
+// reviseAnnouncements brings the messages the jobs sent in line with event,
+// in the background. The messages of the occurrences still scheduled are
+// edited, the others are deleted, or followed up with a cancellation notice
+// when the configuration asks for it. Every occurrence is considered
+// cancelled when the event was deleted.
+func (app *application) reviseAnnouncements(event data.Event, jobs []data.Job, deleted bool) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	app.background(func() {
+		// An occurrence announced by several reminders is only noticed once
+		// per webhook.
+		noticed := make(map[string]bool)
+
+		for _, job := range jobs {
+			err := app.reviseAnnouncement(event, job, deleted, noticed)
+			if err != nil {
+				app.logger.Error("Unable to revise announcement", "event_id", event.ID, "job_id", job.ID, "error", err)
+			}
+		}
+	})
+}
+
+func (app *application) reviseAnnouncement(event data.Event, job data.Job, deleted bool, noticed map[string]bool) error {
+	webhook, err := app.models.Webhooks.GetByID(job.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	notifier, err := app.notifier(webhook)
+	if err != nil {
+		return err
+	}
+
+	editor, ok := notifier.(MessageEditor)
+	if !ok {
+		return nil
+	}
+
+	var instance *data.EventInstance
+	if !deleted && event.IsActive {
+		instance, err = scheduledInstance(event, job.OccurrenceDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+
+	if instance != nil {
+		reminder := jobReminder(event, job)
+
+		msg, err := app.message(event, instance, reminder, messageTemplate(event, webhook, reminder))
+		if err != nil {
+			return err
+		}
+
+		return editor.Edit(ctx, webhook.URL, job.MessageID, notifier.Payload(msg))
+	}
+
+	if app.config.Announcements.OnCancel == cancelNotice {
+		key := fmt.Sprintf("%s %d", webhook.ID, job.OccurrenceDate.Unix())
+		if !noticed[key] {
+			noticed[key] = true
+
+			_, err = app.notify(ctx, webhook, cancelledMessage(event, job.OccurrenceDate))
+		}
+	} else {
+		err = editor.Delete(ctx, webhook.URL, job.MessageID)
+	}
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("Announcement of cancelled occurrence revised", "event_id", event.ID, "job_id", job.ID, "occurrence", job.OccurrenceDate)
+
+	return app.models.Jobs.ClearMessageID(job.ID)
+}
+
+// cancelledMessage is the notice following up the announcement of a
+// cancelled occurrence.
+func cancelledMessage(event data.Event, occurrence time.Time) Message {
+	if loc, err := time.LoadLocation(event.Timezone); err == nil {
+		occurrence = occurrence.In(loc)
+	}
+
+	return Message{
+		EventID:     event.ID,
+		Content:     fmt.Sprintf("%s is cancelled", event.Title),
+		Title:       event.Title,
+		Description: fmt.Sprintf("The occurrence of %s is cancelled.", announcementTime(occurrence)),
+		// https://gist.github.com/thomasbnt/b6f455e2c7d743b796917fa3c205f812
+		Color:     0xE74C3C,
+		StartDate: occurrence,
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// calendarFeedHandler serves the active events as an iCalendar feed calendar
+// apps can subscribe to.
+func (app *application) calendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.authorizeCalendarFeed(w, r) {
+		return
+	}
+
+	events, err := app.models.Events.GetActiveEvents()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeCalendar(w, r, app.config.Calendar.Name, events)
+}
+
+// tagCalendarFeedHandler serves the active events carrying the tag as an
+// iCalendar feed.
+func (app *application) tagCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.authorizeCalendarFeed(w, r) {
+		return
+	}
+
+	tagID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tag, err := app.models.Tags.GetByID(tagID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if tag == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	events, err := app.models.Events.GetActiveEvents()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	events = slices.DeleteFunc(events, func(event data.Event) bool {
+		return !slices.ContainsFunc(event.Tags, func(t data.Tag) bool { return t.ID == tag.ID })
+	})
+
+	app.writeCalendar(w, r, app.config.Calendar.Name+" - "+tag.Name, events)
+}
+
+// authorizeCalendarFeed checks the feed token given in ?token=, calendar apps
+// cannot send an Authorization header. The token is only required when the
+// feeds are configured as private.
+func (app *application) authorizeCalendarFeed(w http.ResponseWriter, r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+
+	if token == "" {
+		if app.config.Calendar.Private {
+			app.authenticationRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return false
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeCalendarFeed, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) writeCalendar(w http.ResponseWriter, r *http.Request, name string, events []data.Event) {
+	var buf bytes.Buffer
+
+	err := writeCalendar(&buf, name, events)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// calendarFeedURL returns the path of the feed of the tag, or of every event
+// when tagID is uuid.Nil, carrying the feed token.
+func calendarFeedURL(tagID uuid.UUID, token string) string {
+	path := "/v1/calendar.ics"
+	if tagID != uuid.Nil {
+		path = "/v1/tags/" + tagID.String() + "/calendar.ics"
+	}
+
+	return path + "?token=" + token
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// CalendarSource is an external calendar whose events are synchronized into
+// the events table. Changes returns the events changed since the cursor it
+// returned on the previous call, or all of them when cursor is empty, along
+// with the cursor to resume from next time. It returns ErrCursorExpired when
+// the source cannot resume from cursor anymore.
+type CalendarSource interface {
+	Name() string
+	Changes(ctx context.Context, cursor string) ([]CalendarChange, string, error)
+}
+
+var ErrCursorExpired = errors.New("calendar source cursor expired")
+
+// errInvalidChange is the error of the changes which cannot be applied however
+// many times they are tried.
+var errInvalidChange = errors.New("invalid calendar change")
+
+// CalendarChange is an event created, updated or cancelled in a calendar
+// source. When RecurringID is set, it is the instance of that recurring event
+// which the recurrence starts at OriginalStart. Event is left empty for
+// cancellations. Err is set when the source could not read the event, the
+// change is then skipped.
+type CalendarChange struct {
+	ExternalID    string
+	RecurringID   string
+	OriginalStart time.Time
+	Cancelled     bool
+	Event         data.Event
+	Err           error
+}
+
+// calendarSync is a calendar source along with the webhook the events it
+// creates announce on.
+type calendarSync struct {
+	source    CalendarSource
+	webhookID uuid.UUID
+}
+
+// setupCalendarSources returns the calendar sources enabled in the
+// configuration.
+func setupCalendarSources(ctx context.Context, cfg config) ([]calendarSync, error) {
+	var syncs []calendarSync
+
+	if cfg.CalendarSources.Google.Enabled {
+		webhookID, err := uuid.Parse(cfg.CalendarSources.Google.WebhookID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid google calendar webhook ID: %w", err)
+		}
+
+		source, err := newGoogleCalendarSource(ctx, cfg.CalendarSources.Google.CredentialsFile, cfg.CalendarSources.Google.CalendarID)
+		if err != nil {
+			return nil, err
+		}
+
+		syncs = append(syncs, calendarSync{source: source, webhookID: webhookID})
+	}
+
+	return syncs, nil
+}
+
+// startCalendarSync synchronizes the calendar sources in the background every
+// poll interval until ctx is cancelled.
+func (app *application) startCalendarSync(ctx context.Context, syncs []calendarSync) error {
+	if len(syncs) == 0 {
+		return nil
+	}
+
+	pollInterval, err := time.ParseDuration(app.config.CalendarSources.PollInterval)
+	if err != nil {
+		return fmt.Errorf("invalid calendar sources poll interval: %w", err)
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			for _, cs := range syncs {
+				app.syncCalendarSource(ctx, cs)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	return nil
+}
+
+// syncCalendarSource applies the changes made in the source since its last
+// synchronization. The cursor only moves forward once every change is
+// applied, the ones which failed are fetched again on the next poll.
+func (app *application) syncCalendarSource(ctx context.Context, cs calendarSync) {
+	name := cs.source.Name()
+
+	cursor, err := app.models.Calendars.GetCursor(name)
+	if err != nil {
+		app.logger.Error("Unable to get calendar source cursor", "source", name, "error", err)
+		return
+	}
+
+	changes, next, err := app.sourceChanges(ctx, cs.source, cursor)
+	if err != nil {
+		app.logger.Error("Unable to list calendar source changes", "source", name, "error", err)
+		return
+	}
+
+	applied := app.applyChanges(name, changes, func(change CalendarChange) error {
+		if change.RecurringID == "" {
+			return app.syncEvent(name, cs.webhookID, change)
+		}
+		return app.syncInstance(name, change)
+	})
+	if !applied {
+		return
+	}
+
+	err = app.models.Calendars.SetCursor(name, next)
+	if err != nil {
+		app.logger.Error("Unable to save calendar source cursor", "source", name, "error", err)
+	}
+}
+
+// sourceChanges returns the changes made in the source since cursor, and
+// every event when the source cannot resume from cursor anymore.
+func (app *application) sourceChanges(ctx context.Context, source CalendarSource, cursor string) ([]CalendarChange, string, error) {
+	changes, next, err := source.Changes(ctx, cursor)
+	if errors.Is(err, ErrCursorExpired) {
+		app.logger.Warn("Calendar source cursor expired, synchronizing every event", "source", source.Name())
+		changes, next, err = source.Changes(ctx, "")
+	}
+
+	return changes, next, err
+}
+
+// applyChanges applies the changes of the source with apply, and reports
+// whether none of them failed. The invalid changes are logged and skipped, as
+// they would fail on every poll and hold the cursor back forever.
+func (app *application) applyChanges(source string, changes []CalendarChange, apply func(CalendarChange) error) bool {
+	// The recurring events go first so that the changes to their instances
+	// find them.
+	slices.SortStableFunc(changes, func(a, b CalendarChange) int {
+		switch {
+		case a.RecurringID == "" && b.RecurringID != "":
+			return -1
+		case a.RecurringID != "" && b.RecurringID == "":
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	applied := true
+	for _, change := range changes {
+		err := change.Err
+		if err == nil {
+			err = apply(change)
+		}
+
+		switch {
+		case err == nil:
+		case change.Err != nil, errors.Is(err, errInvalidChange):
+			app.logger.Warn("Skipping invalid calendar change", "source", source, "external_id", change.ExternalID, "error", err)
+		default:
+			app.logger.Error("Unable to sync event", "source", source, "external_id", change.ExternalID, "error", err)
+			applied = false
+		}
+	}
+
+	return applied
+}
+
+// syncEvent creates, updates or deactivates the event changed in the source.
+// The pending jobs of an event whose schedule changed are dropped, the
+// scheduler materializes them again from the new one.
+func (app *application) syncEvent(source string, webhookID uuid.UUID, change CalendarChange) error {
+	existing, err := app.models.Events.GetByExternalID(source, change.ExternalID)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		if change.Cancelled {
+			return nil
+		}
+	case err != nil:
+		return err
+	}
+
+	if change.Cancelled {
+		if !existing.IsActive {
+			return nil
+		}
+
+		existing.IsActive = false
+		existing.SourceCancelled = true
+		err = app.models.Events.Update(&existing)
+		if err != nil {
+			return err
+		}
+
+		app.refreshScheduledEvents(existing)
+
+		return app.models.Jobs.DeletePendingForEvent(existing.ID)
+	}
+
+	event := syncedEvent(source, webhookID, existing, change)
+
+	v := validator.New()
+	if data.ValidateEvent(v, &event); !v.Valid() {
+		return fmt.Errorf("%w: %v", errInvalidChange, v.Errors)
+	}
+
+	if existing.ID != uuid.Nil && !scheduleChanged(existing, event) {
+		return nil
+	}
+
+	err = app.saveEvent(&event)
+	if err != nil {
+		return err
+	}
+
+	app.refreshScheduledEvents(event)
+
+	if existing.ID != uuid.Nil {
+		return app.models.Jobs.DeletePendingForEvent(event.ID)
+	}
+
+	return nil
+}
+
+// syncedEvent returns the event changed in the source, merged into the stored
+// one when it exists. An event deactivated because it was cancelled in the
+// source is reactivated, while the events paused by users stay paused.
+func syncedEvent(source string, webhookID uuid.UUID, existing data.Event, change CalendarChange) data.Event {
+	event := change.Event
+	event.Source = source
+	event.ExternalID = change.ExternalID
+
+	if existing.ID == uuid.Nil {
+		event.WebhookID = webhookID
+		event.IsActive = true
+		return event
+	}
+
+	event.ID = existing.ID
+	event.WebhookID = existing.WebhookID
+	event.IsActive = existing.IsActive || existing.SourceCancelled
+	event.Template = existing.Template
+	event.Mentions = existing.Mentions
+	event.RSVP = existing.RSVP
+	event.Capacity = existing.Capacity
+	event.CreatedDate = existing.CreatedDate
+
+	return event
+}
+
+// syncInstance stores the change made to one occurrence of a recurring event
+// as an override of it.
+func (app *application) syncInstance(source string, change CalendarChange) error {
+	event, err := app.models.Events.GetByExternalID(source, change.RecurringID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	override := instanceOverride(event, change)
+
+	i := slices.IndexFunc(event.Overrides, func(o data.OccurrenceOverride) bool {
+		return o.OriginalStart.Equal(override.OriginalStart)
+	})
+	if i >= 0 && sameOverride(event.Overrides[i], override) {
+		return nil
+	}
+
+	v := validator.New()
+	if data.ValidateOccurrenceOverride(v, &override); !v.Valid() {
+		return fmt.Errorf("%w: %v", errInvalidChange, v.Errors)
+	}
+
+	err = app.models.Occurrences.UpsertOverride(&override)
+	if err != nil {
+		return err
+	}
+
+	if i >= 0 {
+		event.Overrides[i] = override
+	} else {
+		event.Overrides = append(event.Overrides, override)
+	}
+	app.refreshScheduledEvents(event)
+
+	return app.models.Jobs.DeletePendingForEvent(event.ID)
+}
+
+// instanceOverride returns the override holding the differences between the
+// changed instance and the event it is an occurrence of.
+func instanceOverride(event data.Event, change CalendarChange) data.OccurrenceOverride {
+	override := data.OccurrenceOverride{
+		EventID:       event.ID,
+		OriginalStart: change.OriginalStart,
+		Cancelled:     change.Cancelled,
+	}
+
+	if change.Cancelled {
+		return override
+	}
+
+	instance := change.Event
+
+	if instance.Title != "" && instance.Title != event.Title {
+		override.Title = &instance.Title
+	}
+	if instance.Description != "" && instance.Description != event.Description {
+		override.Description = &instance.Description
+	}
+	if !instance.StartDate.Equal(change.OriginalStart) {
+		override.StartDate = &instance.StartDate
+	}
+	if instance.Duration != event.Duration {
+		override.Duration = &instance.Duration
+	}
+
+	return override
+}
+
+// scheduleChanged reports whether the synchronized event differs from the
+// stored one in a way the announcements depend on.
+func scheduleChanged(stored, synced data.Event) bool {
+	if stored.IsActive != synced.IsActive ||
+		stored.SourceCancelled != synced.SourceCancelled ||
+		stored.Title != synced.Title ||
+		stored.Description != synced.Description ||
+		stored.Duration != synced.Duration ||
+		stored.RRule != synced.RRule ||
+		!stored.StartDate.Equal(synced.StartDate) ||
+		stored.Timezone != synced.Timezone {
+		return true
+	}
+
+	sameDates := func(a, b []time.Time) bool {
+		return slices.EqualFunc(a, b, time.Time.Equal)
+	}
+
+	if !sameDates(stored.ExDates, synced.ExDates) || !sameDates(stored.RDates, synced.RDates) {
+		return true
+	}
+
+	for _, override := range synced.Overrides {
+		i := slices.IndexFunc(stored.Overrides, func(o data.OccurrenceOverride) bool {
+			return o.OriginalStart.Equal(override.OriginalStart)
+		})
+		if i < 0 || !sameOverride(stored.Overrides[i], override) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameOverride(a, b data.OccurrenceOverride) bool {
+	sameString := func(x, y *string) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	sameTime := func(x, y *time.Time) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && x.Equal(*y))
+	}
+
+	return a.Cancelled == b.Cancelled &&
+		sameString(a.Title, b.Title) &&
+		sameString(a.Description, b.Description) &&
+		sameTime(a.StartDate, b.StartDate) &&
+		sameString(a.Duration, b.Duration)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+func TestApplyChanges(t *testing.T) {
+	app := newTestApplication()
+
+	changes := []CalendarChange{
+		{ExternalID: "quiz_1", RecurringID: "quiz"},
+		{ExternalID: "quiz"},
+		{ExternalID: "broken", Err: errors.New("missing date")},
+		{ExternalID: "invalid"},
+	}
+
+	var applied []string
+	ok := app.applyChanges("google", changes, func(change CalendarChange) error {
+		applied = append(applied, change.ExternalID)
+		if change.ExternalID == "invalid" {
+			return fmt.Errorf("%w: title must be provided", errInvalidChange)
+		}
+		return nil
+	})
+
+	if !ok {
+		t.Error("got a failed synchronization, want the invalid changes skipped")
+	}
+	if want := "[quiz invalid quiz_1]"; fmt.Sprint(applied) != want {
+		t.Errorf("got changes applied in order %v, want %s", applied, want)
+	}
+
+	ok = app.applyChanges("google", []CalendarChange{{ExternalID: "raid"}}, func(CalendarChange) error {
+		return errors.New("connection refused")
+	})
+	if ok {
+		t.Error("got a successful synchronization, want the failed change retried")
+	}
+}
+
+func TestSyncedEventActivity(t *testing.T) {
+	webhookID := uuid.New()
+	change := CalendarChange{
+		ExternalID: "raid",
+		Event:      googleTestEvent(t, weeklyItem("raid", "Raid night")),
+	}
+
+	tests := []struct {
+		name       string
+		existing   data.Event
+		wantActive bool
+	}{
+		{
+			name:       "new event",
+			wantActive: true,
+		},
+		{
+			name:       "restored after its cancellation",
+			existing:   data.Event{ID: uuid.New(), IsActive: false, SourceCancelled: true},
+			wantActive: true,
+		},
+		{
+			name:       "paused by a user",
+			existing:   data.Event{ID: uuid.New(), IsActive: false},
+			wantActive: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing
+			if existing.ID != uuid.Nil {
+				// The stored event only differs from the source in activity.
+				stored := change.Event
+				stored.ID = existing.ID
+				stored.IsActive = existing.IsActive
+				stored.SourceCancelled = existing.SourceCancelled
+				existing = stored
+			}
+
+			event := syncedEvent("google", webhookID, existing, change)
+
+			if event.IsActive != tt.wantActive {
+				t.Errorf("got active %t, want %t", event.IsActive, tt.wantActive)
+			}
+			if event.SourceCancelled {
+				t.Error("got an event still marked cancelled in the source")
+			}
+			if existing.ID != uuid.Nil && scheduleChanged(existing, event) != (existing.IsActive != tt.wantActive || existing.SourceCancelled) {
+				t.Errorf("got schedule changed %t, want it to follow the activity", scheduleChanged(existing, event))
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// manageEventsPermission is Discord's Manage Events permission, the commands
+// are only shown to the members who have it by default.
+const manageEventsPermission = "8589934592"
+
+var errAccountNotLinked = errors.New("discord account not linked")
+
+// discordUserStore finds the users by their linked Discord account, as
+// data.UserModel does.
+type discordUserStore interface {
+	GetByDiscordID(discordID string) (*data.User, error)
+}
+
+// slashCommands returns the /events command and its subcommands.
+func slashCommands() []ApplicationCommand {
+	minCount, maxCount := 1, 25
+
+	eventID := ApplicationCommandOption{
+		Type:        optionString,
+		Name:        "id",
+		Description: "ID of the event",
+		Required:    true,
+	}
+
+	return []ApplicationCommand{
+		{
+			Name:                     "events",
+			Description:              "List and manage the events",
+			DefaultMemberPermissions: manageEventsPermission,
+			Options: []ApplicationCommandOption{
+				{
+					Type:        optionSubCommand,
+					Name:        "upcoming",
+					Description: "List the upcoming occurrences",
+					Options: []ApplicationCommandOption{
+						{Type: optionInteger, Name: "count", Description: "How many occurrences to list", MinValue: &minCount, MaxValue: &maxCount},
+					},
+				},
+				{
+					Type:        optionSubCommand,
+					Name:        "info",
+					Description: "Show an event",
+					Options:     []ApplicationCommandOption{eventID},
+				},
+				{
+					Type:        optionSubCommand,
+					Name:        "pause",
+					Description: "Stop announcing an event",
+					Options:     []ApplicationCommandOption{eventID},
+				},
+				{
+					Type:        optionSubCommand,
+					Name:        "create",
+					Description: "Create an event",
+					Options: []ApplicationCommandOption{
+						{Type: optionString, Name: "title", Description: "Title of the event", Required: true},
+						{Type: optionString, Name: "description", Description: "Description of the event", Required: true},
+						{Type: optionString, Name: "start", Description: "First occurrence, such as 2025-06-01 20:30", Required: true},
+						{Type: optionString, Name: "duration", Description: "ISO 8601 duration, PT1H by default"},
+						{Type: optionString, Name: "rrule", Description: "Recurrence rule, such as FREQ=WEEKLY, a single occurrence by default"},
+						{Type: optionString, Name: "timezone", Description: "IANA time zone, UTC by default"},
+						{Type: optionString, Name: "webhook", Description: "ID of the webhook announcing the event"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runCommand runs the slash command of the interaction and returns the reply.
+// Like the REST API, everything but showing an event requires an account,
+// which the Discord user is mapped to by the Discord ID linked to it.
+func (app *application) runCommand(interaction Interaction) InteractionResponseData {
+	if interaction.Data.Name != "events" || len(interaction.Data.Options) == 0 {
+		return ephemeral("Unknown command.")
+	}
+
+	sub := interaction.Data.Options[0]
+	opts := newCommandOptions(sub.Options)
+
+	if sub.Name == "info" {
+		return app.eventInfoCommand(opts)
+	}
+
+	user, err := app.interactionUser(interaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, errAccountNotLinked):
+			return ephemeral("Your Discord account is not linked to a GoEventBot account.")
+		case errors.Is(err, errAccountNotActivated):
+			return ephemeral("Your GoEventBot account is not activated yet.")
+		default:
+			return app.commandError(sub.Name, err)
+		}
+	}
+
+	switch sub.Name {
+	case "upcoming":
+		return app.upcomingEventsCommand(opts)
+	case "pause":
+		return app.pauseEventCommand(user, opts)
+	case "create":
+		return app.createEventCommand(user, opts)
+	default:
+		return ephemeral("Unknown command.")
+	}
+}
+
+func (app *application) interactionUser(interaction Interaction) (*data.User, error) {
+	return linkedUser(app.models.Users, interaction.discordUserID())
+}
+
+// linkedUser returns the user the Discord user is linked to, who must be
+// activated to act as them.
+func linkedUser(users discordUserStore, discordID string) (*data.User, error) {
+	if discordID == "" {
+		return nil, errAccountNotLinked
+	}
+
+	user, err := users.GetByDiscordID(discordID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, errAccountNotLinked
+		}
+		return nil, err
+	}
+
+	if !user.Activated {
+		return nil, errAccountNotActivated
+	}
+
+	return user, nil
+}
+
+// commandError logs an unexpected error and replies without its details.
+func (app *application) commandError(command string, err error) InteractionResponseData {
+	app.logger.Error("Unable to run slash command", "command", command, "error", err)
+
+	return ephemeral("Something went wrong, please try again later.")
+}
+
+// commandEvent returns the event of the id option.
+func (app *application) commandEvent(opts commandOptions) (data.Event, *InteractionResponseData, error) {
+	id, err := uuid.Parse(opts.string("id", ""))
+	if err != nil {
+		reply := ephemeral("The ID must be an event ID.")
+		return data.Event{}, &reply, nil
+	}
+
+	event, err := app.models.Events.Get(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			reply := ephemeral("No event has this ID.")
+			return data.Event{}, &reply, nil
+		}
+		return data.Event{}, nil, err
+	}
+
+	return event, nil, nil
+}
+
+func (app *application) upcomingEventsCommand(opts commandOptions) InteractionResponseData {
+	count := min(max(opts.int("count", 5), 1), 25)
+
+	events, err := app.models.Events.GetActiveEvents()
+	if err != nil {
+		return app.commandError("upcoming", err)
+	}
+
+	now := time.Now()
+
+	instances, err := expandOccurrences(events, now, now.AddDate(0, 1, 0), time.UTC)
+	if err != nil {
+		return app.commandError("upcoming", err)
+	}
+
+	if len(instances) == 0 {
+		return ephemeral("No event in the coming month.")
+	}
+
+	var sb strings.Builder
+	for _, instance := range instances[:min(count, len(instances))] {
+		fmt.Fprintf(&sb, "**%s** %s (%s)\n`%s`\n",
+			instance.Title,
+			discordTimestamp(instance.StartDate, "F"),
+			discordTimestamp(instance.StartDate, "R"),
+			instance.EventID,
+		)
+	}
+
+	return InteractionResponseData{
+		Embeds: []Embed{{
+			Title:       "Upcoming events",
+			Description: sb.String(),
+			Color:       0xE67E22,
+		}},
+		AllowedMentions: &AllowedMentions{Parse: []string{}},
+	}
+}
+
+func (app *application) eventInfoCommand(opts commandOptions) InteractionResponseData {
+	event, reply, err := app.commandEvent(opts)
+	if err != nil {
+		return app.commandError("info", err)
+	}
+	if reply != nil {
+		return *reply
+	}
+
+	next, err := nextInstance(event, time.Now())
+	if err != nil {
+		return app.commandError("info", err)
+	}
+
+	nextOccurrence := "None"
+	if next != nil && event.IsActive {
+		nextOccurrence = discordTimestamp(next.StartDate, "F")
+	}
+
+	recurrence := event.RRule
+	if recurrence == "" {
+		recurrence = "None"
+	}
+
+	status := "Active"
+	if !event.IsActive {
+		status = "Paused"
+	}
+
+	tags := make([]string, len(event.Tags))
+	for i, tag := range event.Tags {
+		tags[i] = tag.Name
+	}
+
+	fields := []EmbedField{
+		{Name: "Next occurrence", Value: nextOccurrence, Inline: true},
+		{Name: "Duration", Value: event.Duration, Inline: true},
+		{Name: "Status", Value: status, Inline: true},
+		{Name: "Recurrence", Value: recurrence},
+	}
+	if len(tags) > 0 {
+		fields = append(fields, EmbedField{Name: "Tags", Value: strings.Join(tags, ", ")})
+	}
+
+	return InteractionResponseData{
+		Embeds: []Embed{{
+			Title:       event.Title,
+			Description: truncate(4096, event.Description),
+			Color:       0xE67E22,
+			Fields:      fields,
+			Footer:      &EmbedFooter{Text: event.ID.String()},
+		}},
+		AllowedMentions: &AllowedMentions{Parse: []string{}},
+	}
+}
+
+// pauseEventCommand disables the event as updateEventHandler does.
+func (app *application) pauseEventCommand(user *data.User, opts commandOptions) InteractionResponseData {
+	event, reply, err := app.commandEvent(opts)
+	if err != nil {
+		return app.commandError("pause", err)
+	}
+	if reply != nil {
+		return *reply
+	}
+
+	if !event.IsActive {
+		return ephemeral("**%s** is already paused.", event.Title)
+	}
+
+	announced, err := app.announcedJobs(event)
+	if err != nil {
+		return app.commandError("pause", err)
+	}
+
+	event.IsActive = false
+
+	err = app.models.Events.Update(&event)
+	if err != nil {
+		return app.commandError("pause", err)
+	}
+
+	err = app.models.Jobs.DeletePendingForEvent(event.ID)
+	if err != nil {
+		app.logger.Error("Unable to reset pending jobs", "event_id", event.ID, "error", err)
+	}
+
+	app.reviseAnnouncements(event, announced, false)
+	app.refreshScheduledEvents(event)
+
+	app.logger.Info("Event paused from Discord", "event_id", event.ID, "user_id", user.ID)
+
+	return ephemeral("**%s** is paused.", event.Title)
+}
+
+// createEventCommand creates an event as createEventHandler does.
+func (app *application) createEventCommand(user *data.User, opts commandOptions) InteractionResponseData {
+	event := data.Event{
+		Title:       opts.string("title", ""),
+		Description: opts.string("description", ""),
+		Duration:    opts.string("duration", "PT1H"),
+		RRule:       opts.string("rrule", "FREQ=DAILY;COUNT=1"),
+		Timezone:    opts.string("timezone", "UTC"),
+		IsActive:    true,
+		WebhookID:   app.interactions.webhookID,
+	}
+
+	v := validator.New()
+
+	if webhook := opts.string("webhook", ""); webhook != "" {
+		id, err := uuid.Parse(webhook)
+		v.Check(err == nil, "webhook", "must be a webhook ID")
+		event.WebhookID = id
+	}
+
+	start, err := parseCommandTime(opts.string("start", ""), event.Timezone)
+	v.Check(err == nil, "start", "must be a date such as 2025-06-01 20:30")
+	event.StartDate = start
+
+	if data.ValidateEvent(v, &event); !v.Valid() {
+		var sb strings.Builder
+		sb.WriteString("The event is invalid:\n")
+		for _, key := range slices.Sorted(maps.Keys(v.Errors)) {
+			fmt.Fprintf(&sb, "- %s %s\n", key, v.Errors[key])
+		}
+		return ephemeral("%s", sb.String())
+	}
+
+	_, err = app.models.Webhooks.GetByID(event.WebhookID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return ephemeral("No webhook has this ID.")
+		}
+		return app.commandError("create", err)
+	}
+
+	err = app.saveEvent(&event)
+	if err != nil {
+		return app.commandError("create", err)
+	}
+
+	app.refreshScheduledEvents(event)
+
+	app.logger.Info("Event created from Discord", "event_id", event.ID, "user_id", user.ID)
+
+	return ephemeral("**%s** is created, it starts %s.\n`%s`", event.Title, discordTimestamp(event.StartDate, "F"), event.ID)
+}
+
+// parseCommandTime parses an RFC 3339 timestamp, or a date and time in the
+// time zone.
+func parseCommandTime(s, timezone string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.ParseInLocation("2006-01-02 15:04", s, loc)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+)
+
+// fakeUserStore keeps the users by their Discord ID.
+type fakeUserStore map[string]*data.User
+
+func (f fakeUserStore) GetByDiscordID(discordID string) (*data.User, error) {
+	user, ok := f[discordID]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+func TestLinkedUser(t *testing.T) {
+	store := fakeUserStore{
+		"1": {Name: "Alice", Activated: true, DiscordID: "1"},
+		"2": {Name: "Bob", DiscordID: "2"},
+	}
+
+	tests := []struct {
+		name      string
+		discordID string
+		wantName  string
+		wantErr   error
+	}{
+		{"activated", "1", "Alice", nil},
+		{"not activated", "2", "", errAccountNotActivated},
+		{"not linked", "3", "", errAccountNotLinked},
+		{"no Discord user", "", "", errAccountNotLinked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := linkedUser(store, tt.discordID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.Name != tt.wantName {
+				t.Errorf("got user %q, want %q", user.Name, tt.wantName)
+			}
+		})
+	}
+}
@@ -1,77 +1,300 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
-	"github.com/google/uuid"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+// Discord message component constants.
+// https://discord.com/developers/docs/interactions/message-components
+const (
+	componentActionRow = 1
+	componentButton    = 2
+
+	buttonSecondary = 2
+	buttonSuccess   = 3
+	buttonDanger    = 4
 )
 
 type DiscordBody struct {
-	Content string  `json:"content"`
-	Embeds  []Embed `json:"embeds"`
+	Content         string           `json:"content"`
+	Embeds          []Embed          `json:"embeds"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+	Components      []Component      `json:"components,omitempty"`
+}
+
+// Component is an action row, or one of the buttons in it.
+type Component struct {
+	Type       int         `json:"type"`
+	Style      int         `json:"style,omitempty"`
+	Label      string      `json:"label,omitempty"`
+	CustomID   string      `json:"custom_id,omitempty"`
+	Components []Component `json:"components,omitempty"`
+}
+
+// AllowedMentions restricts who a message pings, whatever its content.
+// https://discord.com/developers/docs/resources/message#allowed-mentions-object
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
 }
 
 type Embed struct {
-	Color       int    `json:"color"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	TimeStamps  string `json:"timestamp"`
+	Color       int          `json:"color"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	URL         string       `json:"url,omitempty"`
+	TimeStamps  string       `json:"timestamp,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+	Thumbnail   *EmbedImage  `json:"thumbnail,omitempty"`
+	Author      *EmbedAuthor `json:"author,omitempty"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
+type EmbedAuthor struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// discordNotifier posts messages to Discord webhooks as an embed. With
+// rsvpButtons, the announcements of the events taking RSVPs carry buttons
+// answering them, whose clicks reach the interactions endpoint.
+type discordNotifier struct {
+	client      *discordClient
+	rsvpButtons bool
 }
 
-func (app *application) SendMessage(embeds []Embed, title string, webhookId uuid.UUID) error {
+func (n discordNotifier) Payload(msg Message) any {
+	// clampMessage left room for the mentions, which alone may exceed the
+	// limit of the content.
+	content := msg.Content
+	if mentions := discordMentions(msg.Mentions); mentions != "" {
+		content = truncate(messageLimits.content, strings.TrimSpace(mentions+" "+content))
+	}
 
 	body := DiscordBody{
-		Content: title,
-		Embeds:  embeds,
+		Content:         content,
+		Embeds:          FormatMessage(msg),
+		AllowedMentions: newAllowedMentions(msg.Mentions),
 	}
-	bodyJson, err := json.Marshal(body)
-	app.logger.Info("body: ", bodyJson)
-	if err != nil {
-		app.logger.Error("Unable to format body to send the message", err.Error())
-		return err
+	if n.rsvpButtons && msg.Attendance != nil {
+		body.Components = rsvpButtons(msg.EventID, msg.OccurrenceDate)
 	}
-	webhook, err := app.models.Webhooks.GetByID(webhookId)
+
+	return body
+}
+
+// Send waits for Discord to create the message, so that its ID comes back
+// along with it.
+func (n discordNotifier) Send(ctx context.Context, webhookURL string, payload any) (*notifyResponse, error) {
+	u, err := url.Parse(webhookURL)
 	if err != nil {
-		app.logger.Error("Unable to get webhook by ID", "Error", err.Error())
-		return fmt.Errorf("unable to get webhook by ID: %w", err)
+		return nil, redactURLError(err)
+	}
+
+	q := u.Query()
+	q.Set("wait", "true")
+	setWithComponents(q, payload)
+	u.RawQuery = q.Encode()
+
+	resp, err := n.client.Post(ctx, u.String(), payload)
+	if resp == nil {
+		return nil, err
+	}
+
+	res := &notifyResponse{StatusCode: resp.StatusCode, Body: resp.Body}
+	if err == nil {
+		var message struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(resp.Body, &message) == nil {
+			res.MessageID = message.ID
+		}
 	}
-	resp, err := http.Post(webhook.URL, "application/json", bytes.NewBuffer(bodyJson))
+
+	return res, err
+}
+
+func (n discordNotifier) Edit(ctx context.Context, webhookURL, messageID string, payload any) error {
+	messageURL, err := discordMessageURL(webhookURL, messageID, payload)
 	if err != nil {
-		app.logger.Error("Unable to send message", err.Error())
 		return err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			app.logger.Error("Unable to close body")
-		}
-	}(resp.Body)
 
-	if resp.StatusCode != 204 {
-		app.logger.Error("Unable to send message: ", resp.Status)
+	_, err = n.client.Send(ctx, http.MethodPatch, messageURL, payload)
+	return err
+}
+
+// Delete succeeds when the message was already deleted, by hand in Discord
+// for instance.
+func (n discordNotifier) Delete(ctx context.Context, webhookURL, messageID string) error {
+	messageURL, err := discordMessageURL(webhookURL, messageID, nil)
+	if err != nil {
 		return err
 	}
-	return nil
+
+	resp, err := n.client.Send(ctx, http.MethodDelete, messageURL, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// discordMessageURL returns the URL of a message sent by the webhook, keeping
+// the thread the webhook posts to.
+func discordMessageURL(webhookURL, messageID string, payload any) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", redactURLError(err)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/messages/" + url.PathEscape(messageID)
+
+	q := u.Query()
+	q.Del("wait")
+	setWithComponents(q, payload)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// setWithComponents asks Discord to keep the buttons of the payload, which it
+// drops from the messages of webhooks otherwise. Only the webhooks created by
+// the application may send them.
+func setWithComponents(q url.Values, payload any) {
+	if body, ok := payload.(DiscordBody); ok && len(body.Components) > 0 {
+		q.Set("with_components", "true")
+	}
+}
+
+// rsvpButtons are the buttons answering the RSVP to the occurrence of the
+// event its rule starts at occurrence.
+func rsvpButtons(eventID uuid.UUID, occurrence time.Time) []Component {
+	button := func(style int, label, status string) Component {
+		return Component{
+			Type:     componentButton,
+			Style:    style,
+			Label:    label,
+			CustomID: rsvpCustomID(status, eventID, occurrence),
+		}
+	}
+
+	return []Component{{
+		Type: componentActionRow,
+		Components: []Component{
+			button(buttonSuccess, "Going", data.RSVPYes),
+			button(buttonSecondary, "Maybe", data.RSVPMaybe),
+			button(buttonDanger, "Not going", data.RSVPNo),
+		},
+	}}
 }
 
-func FormatMessage(event data.Event) []Embed {
+func FormatMessage(msg Message) []Embed {
 	var embed Embed
 	var embeds []Embed
-	rrule, err := ParseRRule(event.RRule)
-	if err != nil {
-		fmt.Println("Error parsing RRule:", err)
-		return nil
+	embed.Title = msg.Title
+	embed.Description = msg.Description
+	embed.URL = msg.URL
+	embed.Color = msg.Color
+	if !msg.StartDate.IsZero() {
+		embed.TimeStamps = msg.StartDate.Format(time.RFC3339)
+	}
+	for _, field := range msg.Fields {
+		embed.Fields = append(embed.Fields, EmbedField(field))
+	}
+	if msg.Attendance != nil {
+		embed.Fields = append(embed.Fields, attendanceFields(*msg.Attendance)...)
+	}
+	if msg.Footer != "" {
+		embed.Footer = &EmbedFooter{Text: msg.Footer}
+	}
+	if msg.ThumbnailURL != "" {
+		embed.Thumbnail = &EmbedImage{URL: msg.ThumbnailURL}
+	}
+	if msg.Author.Name != "" {
+		author := EmbedAuthor(msg.Author)
+		embed.Author = &author
 	}
-	embed.Title = event.Title
-	embed.Description = event.Description
-	// https://gist.github.com/thomasbnt/b6f455e2c7d743b796917fa3c205f812
-	embed.Color = 15105570
-	embed.TimeStamps = rrule.After(time.Now(), false).Format(time.RFC3339)
 	embeds = append(embeds, embed)
 	return embeds
 }
+
+// attendanceFields renders the RSVP counts of an occurrence, the number of
+// users going out of the capacity when the event has one.
+func attendanceFields(a data.Attendance) []EmbedField {
+	going := strconv.Itoa(a.Going)
+	if a.Capacity > 0 {
+		going = fmt.Sprintf("%d/%d", a.Going, a.Capacity)
+	}
+
+	fields := []EmbedField{
+		{Name: "Going", Value: going, Inline: true},
+		{Name: "Maybe", Value: strconv.Itoa(a.Maybe), Inline: true},
+		{Name: "Not going", Value: strconv.Itoa(a.NotGoing), Inline: true},
+	}
+	if a.Waitlisted > 0 {
+		fields = append(fields, EmbedField{Name: "Waitlist", Value: strconv.Itoa(a.Waitlisted), Inline: true})
+	}
+
+	return fields
+}
+
+// discordMentions formats the mentions as they are written in a message.
+func discordMentions(m data.Mentions) string {
+	var parts []string
+	if m.Everyone {
+		parts = append(parts, "@everyone")
+	}
+	if m.Here {
+		parts = append(parts, "@here")
+	}
+	for _, id := range m.Roles {
+		parts = append(parts, "<@&"+id+">")
+	}
+	for _, id := range m.Users {
+		parts = append(parts, "<@"+id+">")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// newAllowedMentions only allows pinging the given mentions, so that a
+// mention typed in an event's title or description never pings anyone.
+func newAllowedMentions(m data.Mentions) *AllowedMentions {
+	allowed := &AllowedMentions{
+		Parse: []string{},
+		Roles: m.Roles,
+		Users: m.Users,
+	}
+	if m.Everyone || m.Here {
+		allowed.Parse = append(allowed.Parse, "everyone")
+	}
+
+	return allowed
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Discord guild scheduled event constants.
+// https://discord.com/developers/docs/resources/guild-scheduled-event
+const (
+	scheduledEventGuildOnly = 2
+	scheduledEventExternal  = 3
+)
+
+// discordBot calls the Discord REST API as a bot member of a guild. When
+// scheduledEvents is set, it mirrors the occurrences of the events in the
+// guild's scheduled events: the occurrences starting within horizon are
+// mirrored, again every sync interval, as external events taking place at
+// location.
+type discordBot struct {
+	client          *discordClient
+	apiURL          string
+	applicationID   string
+	guildID         string
+	scheduledEvents bool
+	location        string
+	horizon         time.Duration
+	syncInterval    time.Duration
+}
+
+type GuildScheduledEvent struct {
+	ID                 string                       `json:"id,omitempty"`
+	Name               string                       `json:"name"`
+	Description        string                       `json:"description,omitempty"`
+	ScheduledStartTime time.Time                    `json:"scheduled_start_time"`
+	ScheduledEndTime   time.Time                    `json:"scheduled_end_time"`
+	PrivacyLevel       int                          `json:"privacy_level"`
+	EntityType         int                          `json:"entity_type"`
+	EntityMetadata     *GuildScheduledEventMetadata `json:"entity_metadata"`
+}
+
+type GuildScheduledEventMetadata struct {
+	Location string `json:"location"`
+}
+
+// setupDiscordBot returns the bot when a bot token is configured, nil
+// otherwise.
+func setupDiscordBot(cfg config) (*discordBot, error) {
+	if cfg.Discord.BotToken == "" {
+		if cfg.Discord.ScheduledEvents.Enabled {
+			return nil, errors.New("discord bot token must be provided for scheduled events")
+		}
+		return nil, nil
+	}
+
+	if cfg.Discord.GuildID == "" {
+		return nil, errors.New("discord guild ID must be provided along with the bot token")
+	}
+
+	bot := &discordBot{
+		client:          newDiscordBotClient(cfg.Discord.BotToken),
+		apiURL:          strings.TrimSuffix(cfg.Discord.APIURL, "/"),
+		applicationID:   cfg.Discord.ClientID,
+		guildID:         cfg.Discord.GuildID,
+		scheduledEvents: cfg.Discord.ScheduledEvents.Enabled,
+		location:        cfg.Discord.ScheduledEvents.Location,
+	}
+
+	var err error
+
+	bot.horizon, err = time.ParseDuration(cfg.Discord.ScheduledEvents.Horizon)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduled events horizon: %w", err)
+	}
+
+	bot.syncInterval, err = time.ParseDuration(cfg.Discord.ScheduledEvents.SyncInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduled events sync interval: %w", err)
+	}
+
+	return bot, nil
+}
+
+func (b *discordBot) scheduledEventsURL(id string) string {
+	u := b.apiURL + "/guilds/" + url.PathEscape(b.guildID) + "/scheduled-events"
+	if id != "" {
+		u += "/" + url.PathEscape(id)
+	}
+
+	return u
+}
+
+// CreateScheduledEvent creates the scheduled event and returns its ID.
+func (b *discordBot) CreateScheduledEvent(ctx context.Context, event GuildScheduledEvent) (string, error) {
+	resp, err := b.client.Send(ctx, http.MethodPost, b.scheduledEventsURL(""), event)
+	if err != nil {
+		return "", err
+	}
+
+	var created GuildScheduledEvent
+	err = json.Unmarshal(resp.Body, &created)
+	if err != nil {
+		return "", err
+	}
+
+	if created.ID == "" {
+		return "", errors.New("discord returned a scheduled event without ID")
+	}
+
+	return created.ID, nil
+}
+
+func (b *discordBot) EditScheduledEvent(ctx context.Context, id string, event GuildScheduledEvent) error {
+	_, err := b.client.Send(ctx, http.MethodPatch, b.scheduledEventsURL(id), event)
+	return err
+}
+
+// DeleteScheduledEvent succeeds when the scheduled event was already deleted,
+// by hand in Discord for instance.
+func (b *discordBot) DeleteScheduledEvent(ctx context.Context, id string) error {
+	resp, err := b.client.Send(ctx, http.MethodDelete, b.scheduledEventsURL(id), nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// RegisterCommands replaces the slash commands of the application in the
+// guild. Guild commands are available right away, unlike global ones.
+func (b *discordBot) RegisterCommands(ctx context.Context, commands []ApplicationCommand) error {
+	if b.applicationID == "" {
+		return errors.New("discord client ID must be provided to register commands")
+	}
+
+	u := b.apiURL + "/applications/" + url.PathEscape(b.applicationID) + "/guilds/" + url.PathEscape(b.guildID) + "/commands"
+
+	_, err := b.client.Send(ctx, http.MethodPut, u, commands)
+	return err
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimitWait is the longest the client blocks waiting for a bucket to
+// reset. Longer waits are returned as errors so the job is retried later
+// instead of holding the worker.
+const maxRateLimitWait = time.Minute
+
+// maxRateLimitRetries bounds how many 429 responses a single send tolerates.
+const maxRateLimitRetries = 3
+
+// bucketSweepInterval is how often the buckets no send is using and whose
+// limit has reset are dropped.
+const bucketSweepInterval = 10 * time.Minute
+
+type discordResponse struct {
+	StatusCode int
+	Body       []byte
+	rateLimit  rateLimitHeaders
+}
+
+// rateLimitBucket tracks what Discord reported for one webhook. Its mutex
+// also serializes the sends to that webhook, so a burst of messages queues
+// up behind the bucket instead of racing for the remaining requests.
+type rateLimitBucket struct {
+	mu        sync.Mutex
+	remaining int
+	resetAt   time.Time
+	// users counts the sends holding the bucket, guarded by the client's
+	// mutex.
+	users int
+}
+
+// discordClient posts to Discord webhooks while honoring the rate limits
+// announced in the response headers, both per webhook and global. The client
+// of a bot authenticates its requests with the bot's token.
+type discordClient struct {
+	httpClient    *http.Client
+	authorization string
+
+	mu            sync.Mutex
+	buckets       map[string]*rateLimitBucket
+	sweptAt       time.Time
+	globalResetAt time.Time
+}
+
+func newDiscordClient() *discordClient {
+	return &discordClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		buckets:    make(map[string]*rateLimitBucket),
+	}
+}
+
+func newDiscordBotClient(token string) *discordClient {
+	c := newDiscordClient()
+	c.authorization = "Bot " + token
+
+	return c
+}
+
+// acquire returns the bucket of key, to be released once the send is done.
+func (c *discordClient) acquire(key string) *rateLimitBucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now := time.Now(); now.Sub(c.sweptAt) >= bucketSweepInterval {
+		c.evictIdleBuckets(now)
+		c.sweptAt = now
+	}
+
+	b, ok := c.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{remaining: 1}
+		c.buckets[key] = b
+	}
+	b.users++
+
+	return b
+}
+
+func (c *discordClient) release(b *rateLimitBucket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b.users--
+}
+
+// evictIdleBuckets drops the buckets that have nothing left to enforce, so
+// the deleted and rotated webhooks don't accumulate. c.mu must be held.
+func (c *discordClient) evictIdleBuckets(now time.Time) {
+	for key, b := range c.buckets {
+		if b.users == 0 && !now.Before(b.resetAt) {
+			delete(c.buckets, key)
+		}
+	}
+}
+
+// rateLimitKey returns the bucket of rawURL. Discord limits a webhook as a
+// whole, whatever the query or the message edited, and the bot routes by
+// path.
+func rateLimitKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "webhooks" && len(segments) >= i+3 {
+			return strings.Join(segments[i:i+3], "/")
+		}
+	}
+
+	return u.Host + u.Path
+}
+
+func (c *discordClient) globalWait() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return time.Until(c.globalResetAt)
+}
+
+func (c *discordClient) setGlobalReset(after time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resetAt := time.Now().Add(after)
+	if resetAt.After(c.globalResetAt) {
+		c.globalResetAt = resetAt
+	}
+}
+
+// Post sends payload as JSON to webhookURL.
+func (c *discordClient) Post(ctx context.Context, webhookURL string, payload any) (*discordResponse, error) {
+	return c.Send(ctx, http.MethodPost, webhookURL, payload)
+}
+
+// Send sends payload as JSON to webhookURL with method, without a body when
+// payload is nil. Requests are delayed while the webhook bucket or the global
+// bucket is exhausted, and 429 responses are retried after the delay Discord
+// asks for. A non-2xx response is returned along with an error.
+func (c *discordClient) Send(ctx context.Context, method, webhookURL string, payload any) (*discordResponse, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := c.acquire(rateLimitKey(webhookURL))
+	defer c.release(b)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		wait := c.globalWait()
+		if b.remaining <= 0 {
+			wait = max(wait, time.Until(b.resetAt))
+		}
+
+		err := sleepContext(ctx, wait)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.do(ctx, method, webhookURL, body)
+		if err != nil {
+			return nil, err
+		}
+
+		retryAfter, global := b.update(resp)
+		if global {
+			c.setGlobalReset(retryAfter)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return resp, fmt.Errorf("discord responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(resp.Body))
+		}
+
+		return resp, nil
+	}
+}
+
+func (c *discordClient) do(ctx context.Context, method, webhookURL string, body []byte) (*discordResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, webhookURL, reqBody)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 1_048_576))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &discordResponse{
+		StatusCode: res.StatusCode,
+		Body:       resBody,
+	}
+
+	resp.parseRateLimit(res.Header)
+
+	return resp, nil
+}
+
+// update records the rate limit state carried by resp and, for a 429, returns
+// how long to wait and whether the limit applies to every route.
+func (b *rateLimitBucket) update(resp *discordResponse) (time.Duration, bool) {
+	if resp.rateLimit.hasRemaining {
+		b.remaining = resp.rateLimit.remaining
+		b.resetAt = time.Now().Add(resp.rateLimit.resetAfter)
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	var limited struct {
+		RetryAfter float64 `json:"retry_after"`
+		Global     bool    `json:"global"`
+	}
+	_ = json.Unmarshal(resp.Body, &limited)
+
+	retryAfter := secondsToDuration(limited.RetryAfter)
+	if retryAfter <= 0 {
+		retryAfter = resp.rateLimit.resetAfter
+	}
+
+	global := limited.Global || resp.rateLimit.global
+	if !global {
+		b.remaining = 0
+		b.resetAt = time.Now().Add(retryAfter)
+	}
+
+	return retryAfter, global
+}
+
+type rateLimitHeaders struct {
+	hasRemaining bool
+	remaining    int
+	resetAfter   time.Duration
+	global       bool
+}
+
+func (resp *discordResponse) parseRateLimit(h http.Header) {
+	if remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining")); err == nil {
+		resp.rateLimit.hasRemaining = true
+		resp.rateLimit.remaining = remaining
+	}
+
+	if resetAfter, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		resp.rateLimit.resetAfter = secondsToDuration(resetAfter)
+	}
+
+	resp.rateLimit.global = h.Get("X-RateLimit-Global") == "true" || h.Get("X-RateLimit-Scope") == "global"
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	if d > maxRateLimitWait {
+		return fmt.Errorf("discord rate limit resets in %s", d.Round(time.Second))
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
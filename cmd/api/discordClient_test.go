@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    rateLimitHeaders
+	}{
+		{
+			name: "bucket",
+			headers: map[string]string{
+				"X-RateLimit-Remaining":   "4",
+				"X-RateLimit-Reset-After": "1.5",
+			},
+			want: rateLimitHeaders{hasRemaining: true, remaining: 4, resetAfter: 1500 * time.Millisecond},
+		},
+		{
+			name: "exhausted",
+			headers: map[string]string{
+				"X-RateLimit-Remaining":   "0",
+				"X-RateLimit-Reset-After": "0.25",
+			},
+			want: rateLimitHeaders{hasRemaining: true, resetAfter: 250 * time.Millisecond},
+		},
+		{
+			name:    "global flag",
+			headers: map[string]string{"X-RateLimit-Global": "true"},
+			want:    rateLimitHeaders{global: true},
+		},
+		{
+			name:    "global scope",
+			headers: map[string]string{"X-RateLimit-Scope": "global"},
+			want:    rateLimitHeaders{global: true},
+		},
+		{
+			name:    "shared scope",
+			headers: map[string]string{"X-RateLimit-Scope": "shared"},
+			want:    rateLimitHeaders{},
+		},
+		{
+			name: "malformed",
+			headers: map[string]string{
+				"X-RateLimit-Remaining":   "many",
+				"X-RateLimit-Reset-After": "soon",
+			},
+			want: rateLimitHeaders{},
+		},
+		{
+			name: "none",
+			want: rateLimitHeaders{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			for key, value := range tt.headers {
+				h.Set(key, value)
+			}
+
+			var resp discordResponse
+			resp.parseRateLimit(h)
+
+			if resp.rateLimit != tt.want {
+				t.Errorf("got %+v, want %+v", resp.rateLimit, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitBucketUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		body           string
+		headers        rateLimitHeaders
+		wantRetryAfter time.Duration
+		wantGlobal     bool
+		wantRemaining  int
+	}{
+		{
+			name:          "success",
+			status:        http.StatusOK,
+			headers:       rateLimitHeaders{hasRemaining: true, remaining: 3, resetAfter: time.Second},
+			wantRemaining: 3,
+		},
+		{
+			name:           "retry after",
+			status:         http.StatusTooManyRequests,
+			body:           `{"retry_after": 0.75, "global": false}`,
+			wantRetryAfter: 750 * time.Millisecond,
+		},
+		{
+			name:           "retry after from the headers",
+			status:         http.StatusTooManyRequests,
+			body:           `not json`,
+			headers:        rateLimitHeaders{hasRemaining: true, resetAfter: 2 * time.Second},
+			wantRetryAfter: 2 * time.Second,
+		},
+		{
+			name:           "global",
+			status:         http.StatusTooManyRequests,
+			body:           `{"retry_after": 3, "global": true}`,
+			wantRetryAfter: 3 * time.Second,
+			wantGlobal:     true,
+			wantRemaining:  1,
+		},
+		{
+			name:           "global from the headers",
+			status:         http.StatusTooManyRequests,
+			body:           `{"retry_after": 1}`,
+			headers:        rateLimitHeaders{global: true},
+			wantRetryAfter: time.Second,
+			wantGlobal:     true,
+			wantRemaining:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &rateLimitBucket{remaining: 1}
+			resp := &discordResponse{StatusCode: tt.status, Body: []byte(tt.body), rateLimit: tt.headers}
+
+			retryAfter, global := b.update(resp)
+
+			if retryAfter != tt.wantRetryAfter || global != tt.wantGlobal {
+				t.Errorf("got (%s, %t), want (%s, %t)", retryAfter, global, tt.wantRetryAfter, tt.wantGlobal)
+			}
+			if b.remaining != tt.wantRemaining {
+				t.Errorf("got %d remaining, want %d", b.remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://discord.com/api/webhooks/123/token", "webhooks/123/token"},
+		{"https://discord.com/api/webhooks/123/token?wait=true", "webhooks/123/token"},
+		{"https://discord.com/api/v10/webhooks/123/token/messages/456", "webhooks/123/token"},
+		{"https://discord.com/api/webhooks/123/other", "webhooks/123/other"},
+		{"https://discord.com/api/v10/guilds/1/scheduled-events?with_user_count=true", "discord.com/api/v10/guilds/1/scheduled-events"},
+	}
+
+	for _, tt := range tests {
+		if got := rateLimitKey(tt.url); got != tt.want {
+			t.Errorf("rateLimitKey(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordClientRetriesAfter429(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"message": "You are being rate limited.", "retry_after": 0.2, "global": false}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := newDiscordClient()
+
+	start := time.Now()
+	resp, err := c.Post(context.Background(), server.URL+"/webhooks/123/token?wait=true", map[string]string{"content": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("got the retry after %s, want it after the 200ms asked for", elapsed)
+	}
+}
+
+func TestDiscordClientEvictsIdleBuckets(t *testing.T) {
+	c := newDiscordClient()
+
+	idle := c.acquire("webhooks/1/idle")
+	c.release(idle)
+
+	limited := c.acquire("webhooks/2/limited")
+	limited.resetAt = time.Now().Add(time.Hour)
+	c.release(limited)
+
+	busy := c.acquire("webhooks/3/busy")
+	defer c.release(busy)
+
+	// The next acquire sweeps the buckets.
+	c.sweptAt = time.Time{}
+	c.acquire("webhooks/4/new")
+
+	if _, ok := c.buckets["webhooks/1/idle"]; ok {
+		t.Error("got the idle bucket kept, want it evicted")
+	}
+	for _, key := range []string{"webhooks/2/limited", "webhooks/3/busy", "webhooks/4/new"} {
+		if _, ok := c.buckets[key]; !ok {
+			t.Errorf("got the %s bucket evicted, want it kept", key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+)
+
+func TestDiscordPayloadMentions(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		mentions    data.Mentions
+		wantContent string
+		wantAllowed string
+	}{
+		{
+			name:        "nobody",
+			content:     "Raid night with <@&111111111111111111> and @everyone",
+			wantContent: "Raid night with <@&111111111111111111> and @everyone",
+			wantAllowed: `{"parse":[]}`,
+		},
+		{
+			name:    "roles and users",
+			content: "Raid night",
+			mentions: data.Mentions{
+				Roles: []string{"111111111111111111", "222222222222222222"},
+				Users: []string{"333333333333333333"},
+			},
+			wantContent: "<@&111111111111111111> <@&222222222222222222> <@333333333333333333> Raid night",
+			wantAllowed: `{"parse":[],"roles":["111111111111111111","222222222222222222"],"users":["333333333333333333"]}`,
+		},
+		{
+			name:        "everyone",
+			content:     "Raid night",
+			mentions:    data.Mentions{Everyone: true},
+			wantContent: "@everyone Raid night",
+			wantAllowed: `{"parse":["everyone"]}`,
+		},
+		{
+			name:        "here",
+			content:     "Raid night",
+			mentions:    data.Mentions{Here: true, Users: []string{"333333333333333333"}},
+			wantContent: "@here <@333333333333333333> Raid night",
+			wantAllowed: `{"parse":["everyone"],"users":["333333333333333333"]}`,
+		},
+		{
+			name:        "no content",
+			mentions:    data.Mentions{Everyone: true, Here: true},
+			wantContent: "@everyone @here",
+			wantAllowed: `{"parse":["everyone"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := discordNotifier{}.Payload(Message{Content: tt.content, Mentions: tt.mentions}).(DiscordBody)
+
+			if body.Content != tt.wantContent {
+				t.Errorf("got content %q, want %q", body.Content, tt.wantContent)
+			}
+
+			allowed, err := json.Marshal(body.AllowedMentions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(allowed) != tt.wantAllowed {
+				t.Errorf("got allowed_mentions %s, want %s", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -6,10 +6,7 @@ import (
 )
 
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.Error("Error while processing the request: ", err, map[string]string{
-		"request_method": r.Method,
-		"request_url":    r.URL.String(),
-	})
+	app.logger.Error("Error while processing the request", "error", err, "request_method", r.Method, "request_url", r.URL.String())
 }
 
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
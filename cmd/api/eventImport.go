@@ -0,0 +1,404 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	duration "github.com/channelmeter/iso8601duration"
+	"github.com/google/uuid"
+)
+
+// importedEvent is an event read from an iCalendar file. Its overrides are
+// the modified occurrences found in the file.
+type importedEvent struct {
+	UID    string     `json:"uid"`
+	Action string     `json:"action"`
+	Event  data.Event `json:"event"`
+}
+
+// importEventsHandler creates or updates an event for every VEVENT of the
+// text/calendar body. An event is matched on its iCalendar UID, or on its ID
+// when the UID is one of ours, so importing the same file twice updates the
+// events it created the first time. The events are saved in a single
+// transaction, and nothing is written when one of them is invalid, nor with
+// ?dry_run=true. New events are attached to the
+// webhook given in ?webhook_id=.
+func (app *application) importEventsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	dryRun := app.readString(qs, "dry_run", "false") == "true"
+
+	var webhookID uuid.UUID
+	if s := app.readString(qs, "webhook_id", ""); s != "" {
+		var err error
+		webhookID, err = uuid.Parse(s)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid webhook_id parameter"))
+			return
+		}
+
+		_, err = app.models.Webhooks.GetByID(webhookID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.badRequestResponse(w, r, errors.New("webhook_id does not match any webhook"))
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	components, err := parseCalendar(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	imported, errs := importICalEvents(components)
+
+	for i := range imported {
+		if _, ok := errs[imported[i].UID]; ok {
+			continue
+		}
+
+		err := resolveImportedEvent(app.models.Events, &imported[i], webhookID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		v := validator.New()
+		data.ValidateEvent(v, &imported[i].Event)
+		for _, override := range imported[i].Event.Overrides {
+			data.ValidateOccurrenceOverride(v, &override)
+		}
+		if !v.Valid() {
+			errs[imported[i].UID] = v.Errors
+		}
+	}
+
+	if len(errs) > 0 {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	if !dryRun {
+		err = app.saveImportedEvents(imported)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": dryRun, "events": imported}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importedEventStore finds the events an import may update, as
+// data.EventModel does.
+type importedEventStore interface {
+	Get(ID uuid.UUID) (data.Event, error)
+	GetByICalUID(uid string) (data.Event, error)
+}
+
+// resolveImportedEvent looks up the event previously imported with the same
+// UID and decides whether the import creates or updates it.
+func resolveImportedEvent(events importedEventStore, imported *importedEvent, webhookID uuid.UUID) error {
+	existing, err := events.GetByICalUID(imported.UID)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		if id, parseErr := uuid.Parse(imported.UID); parseErr == nil {
+			existing, err = events.Get(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				err = data.ErrRecordNotFound
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		imported.Action = "updated"
+		imported.Event.ID = existing.ID
+		imported.Event.IsActive = existing.IsActive
+		imported.Event.WebhookID = existing.WebhookID
+		imported.Event.ICalUID = existing.ICalUID
+		imported.Event.Tags = existing.Tags
+		imported.Event.Template = existing.Template
+		imported.Event.Mentions = existing.Mentions
+		imported.Event.RSVP = existing.RSVP
+		imported.Event.Capacity = existing.Capacity
+		imported.Event.CreatedDate = existing.CreatedDate
+		if webhookID != uuid.Nil {
+			imported.Event.WebhookID = webhookID
+		}
+	case errors.Is(err, data.ErrRecordNotFound):
+		imported.Action = "created"
+		imported.Event.IsActive = true
+		imported.Event.WebhookID = webhookID
+		imported.Event.ICalUID = imported.UID
+	default:
+		return err
+	}
+
+	return nil
+}
+
+// saveImportedEvents saves the events in a single transaction, then
+// reschedules the updated ones.
+func (app *application) saveImportedEvents(imported []importedEvent) error {
+	events := make([]*data.Event, len(imported))
+	for i := range imported {
+		events[i] = &imported[i].Event
+	}
+
+	err := app.models.Events.Import(events)
+	if err != nil {
+		return err
+	}
+
+	for _, imported := range imported {
+		app.refreshScheduledEvents(imported.Event)
+
+		if imported.Action == "updated" {
+			err := app.models.Jobs.DeletePendingForEvent(imported.Event.ID)
+			if err != nil {
+				app.logger.Error("Unable to reset pending jobs", "event_id", imported.Event.ID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// importICalEvents converts the VEVENTs to events, keyed by UID errors for
+// the ones which cannot be read. The VEVENTs carrying a RECURRENCE-ID become
+// overrides of the occurrence of the event sharing their UID.
+func importICalEvents(components []icalComponent) ([]importedEvent, map[string]map[string]string) {
+	var imported []importedEvent
+	errs := make(map[string]map[string]string)
+	index := make(map[string]int)
+
+	var modified []icalComponent
+
+	for _, c := range components {
+		uid := c.text("UID")
+
+		v := validator.New()
+		v.Check(uid != "", "uid", "must be provided")
+		if !v.Valid() {
+			errs[uid] = v.Errors
+			continue
+		}
+
+		if _, ok := c.get("RECURRENCE-ID"); ok {
+			modified = append(modified, c)
+			continue
+		}
+
+		_, duplicate := index[uid]
+		v.Check(!duplicate, "uid", "must be unique in the calendar")
+
+		event := eventFromICal(v, c)
+		if !v.Valid() {
+			errs[uid] = v.Errors
+			continue
+		}
+
+		index[uid] = len(imported)
+		imported = append(imported, importedEvent{UID: uid, Event: event})
+	}
+
+	for _, c := range modified {
+		uid := c.text("UID")
+
+		i, ok := index[uid]
+		if !ok {
+			if _, failed := errs[uid]; !failed {
+				errs[uid] = map[string]string{"recurrence-id": "must belong to an event of the calendar"}
+			}
+			continue
+		}
+
+		v := validator.New()
+		override := overrideFromICal(v, c, imported[i].Event)
+		if !v.Valid() {
+			errs[uid] = v.Errors
+			continue
+		}
+
+		imported[i].Event.Overrides = append(imported[i].Event.Overrides, override)
+	}
+
+	return imported, errs
+}
+
+// eventFromICal reads the event from a VEVENT. A VEVENT without RRULE occurs
+// once, and one without DESCRIPTION is described by its SUMMARY.
+func eventFromICal(v *validator.Validator, c icalComponent) data.Event {
+	event := data.Event{
+		Title:       c.text("SUMMARY"),
+		Description: c.text("DESCRIPTION"),
+		RRule:       "FREQ=DAILY;COUNT=1",
+		Timezone:    "UTC",
+		ExDates:     []time.Time{},
+		RDates:      []time.Time{},
+		Overrides:   []data.OccurrenceOverride{},
+	}
+
+	if event.Description == "" {
+		event.Description = event.Title
+	}
+
+	if p, ok := c.get("RRULE"); ok {
+		event.RRule = p.Value
+	}
+
+	dtstart, ok := c.get("DTSTART")
+	if !ok {
+		v.AddError("dtstart", "must be provided")
+		return event
+	}
+
+	starts, loc, err := icalTimes(dtstart)
+	if err != nil {
+		v.AddError("dtstart", err.Error())
+		return event
+	}
+	event.StartDate = starts[0]
+	event.Timezone = loc.String()
+
+	event.Duration, err = icalDuration(c, event.StartDate, dtstart.Params["VALUE"] == "DATE")
+	if err != nil {
+		v.AddError("duration", err.Error())
+	}
+
+	for _, p := range c.all("EXDATE") {
+		dates, _, err := icalTimes(p)
+		if err != nil {
+			v.AddError("exdate", err.Error())
+			continue
+		}
+		event.ExDates = append(event.ExDates, dates...)
+	}
+
+	for _, p := range c.all("RDATE") {
+		dates, _, err := icalTimes(p)
+		if err != nil {
+			v.AddError("rdate", err.Error())
+			continue
+		}
+		event.RDates = append(event.RDates, dates...)
+	}
+
+	return event
+}
+
+// overrideFromICal reads the changes a VEVENT with a RECURRENCE-ID makes to
+// the occurrence of event.
+func overrideFromICal(v *validator.Validator, c icalComponent, event data.Event) data.OccurrenceOverride {
+	var override data.OccurrenceOverride
+
+	recurrenceID, _ := c.get("RECURRENCE-ID")
+
+	originals, _, err := icalTimes(recurrenceID)
+	if err != nil {
+		v.AddError("recurrence-id", err.Error())
+		return override
+	}
+	override.OriginalStart = originals[0]
+
+	if strings.EqualFold(c.text("STATUS"), "CANCELLED") {
+		override.Cancelled = true
+		return override
+	}
+
+	if title := c.text("SUMMARY"); title != "" && title != event.Title {
+		override.Title = &title
+	}
+	if description := c.text("DESCRIPTION"); description != "" && description != event.Description {
+		override.Description = &description
+	}
+
+	start := override.OriginalStart
+	if p, ok := c.get("DTSTART"); ok {
+		starts, _, err := icalTimes(p)
+		if err != nil {
+			v.AddError("dtstart", err.Error())
+			return override
+		}
+		start = starts[0]
+		if !start.Equal(override.OriginalStart) {
+			override.StartDate = &start
+		}
+	}
+
+	_, hasDuration := c.get("DURATION")
+	_, hasEnd := c.get("DTEND")
+	if hasDuration || hasEnd {
+		d, err := icalDuration(c, start, false)
+		if err != nil {
+			v.AddError("duration", err.Error())
+		} else if d != event.Duration {
+			override.Duration = &d
+		}
+	}
+
+	return override
+}
+
+// icalDuration returns the ISO 8601 duration of the VEVENT, from its
+// DURATION or the time between start and its DTEND. Without either it lasts
+// a day when it is an all-day event and nothing otherwise.
+func icalDuration(c icalComponent, start time.Time, allDay bool) (string, error) {
+	if p, ok := c.get("DURATION"); ok {
+		if _, err := duration.FromString(p.Value); err != nil {
+			return "", errors.New("must be a valid ISO 8601 duration")
+		}
+		return p.Value, nil
+	}
+
+	p, ok := c.get("DTEND")
+	if !ok {
+		if allDay {
+			return "P1D", nil
+		}
+		return "PT0S", nil
+	}
+
+	ends, _, err := icalTimes(p)
+	if err != nil {
+		return "", err
+	}
+	if ends[0].Before(start) {
+		return "", errors.New("DTEND must not be before DTSTART")
+	}
+
+	return isoDuration(ends[0].Sub(start)), nil
+}
+
+// isoDuration formats d as an ISO 8601 duration, in days and smaller units.
+func isoDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+
+	iso := duration.Duration{
+		Days:    days,
+		Hours:   int(d / time.Hour),
+		Minutes: int(d % time.Hour / time.Minute),
+		Seconds: int(d % time.Minute / time.Second),
+	}
+	if iso == (duration.Duration{}) {
+		return "PT0S"
+	}
+
+	return iso.String()
+}
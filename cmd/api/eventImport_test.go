@@ -0,0 +1,358 @@
+package main
+
+import (
+	"database/sql"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+// parseTestCalendar parses the VEVENTs, each given as its content lines.
+func parseTestCalendar(t *testing.T, vevents ...[]string) []icalComponent {
+	t.Helper()
+
+	lines := []string{"BEGIN:VCALENDAR"}
+	for _, vevent := range vevents {
+		lines = append(lines, "BEGIN:VEVENT")
+		lines = append(lines, vevent...)
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	components, err := parseCalendar(strings.NewReader(strings.Join(lines, "\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return components
+}
+
+func TestImportICalEventsDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		vevent  []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "duration",
+			vevent: []string{"DTSTART:20250602T200000Z", "DURATION:PT1H30M"},
+			want:   "PT1H30M",
+		},
+		{
+			name:   "end",
+			vevent: []string{"DTSTART:20250602T200000Z", "DTEND:20250602T223000Z"},
+			want:   "PT2H30M",
+		},
+		{
+			name:   "duration over end",
+			vevent: []string{"DTSTART:20250602T200000Z", "DTEND:20250602T230000Z", "DURATION:PT1H"},
+			want:   "PT1H",
+		},
+		{
+			name:   "end in another time zone",
+			vevent: []string{"DTSTART;TZID=Europe/Paris:20250602T200000", "DTEND:20250602T190000Z"},
+			want:   "PT1H",
+		},
+		{
+			name:   "end days later",
+			vevent: []string{"DTSTART:20250602T200000Z", "DTEND:20250604T210000Z"},
+			want:   "P2DT1H",
+		},
+		{
+			name:   "all day",
+			vevent: []string{"DTSTART;VALUE=DATE:20250602"},
+			want:   "P1D",
+		},
+		{
+			name:   "no end",
+			vevent: []string{"DTSTART:20250602T200000Z"},
+			want:   "PT0S",
+		},
+		{
+			name:    "end before start",
+			vevent:  []string{"DTSTART:20250602T200000Z", "DTEND:20250602T190000Z"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			vevent:  []string{"DTSTART:20250602T200000Z", "DURATION:an hour"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vevent := append([]string{"UID:raid@example.com", "SUMMARY:Raid night"}, tt.vevent...)
+
+			imported, errs := importICalEvents(parseTestCalendar(t, vevent))
+			if tt.wantErr {
+				if _, ok := errs["raid@example.com"]["duration"]; !ok {
+					t.Errorf("got errors %v, want a duration error", errs)
+				}
+				return
+			}
+
+			if len(errs) != 0 {
+				t.Fatalf("got errors %v", errs)
+			}
+			if got := imported[0].Event.Duration; got != tt.want {
+				t.Errorf("got duration %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportICalEventsTimezone(t *testing.T) {
+	imported, errs := importICalEvents(parseTestCalendar(t, []string{
+		"UID:raid@example.com",
+		"SUMMARY:Raid night",
+		"DTSTART;TZID=America/New_York:20250602T200000",
+		"DURATION:PT1H",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"EXDATE;TZID=America/New_York:20250609T200000,20250616T200000",
+		"EXDATE:20250623T200000Z",
+		"RDATE;TZID=America/New_York:20250701T200000",
+	}))
+	if len(errs) != 0 {
+		t.Fatalf("got errors %v", errs)
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := imported[0].Event
+
+	if event.Timezone != "America/New_York" {
+		t.Errorf("got time zone %q, want America/New_York", event.Timezone)
+	}
+	if want := time.Date(2025, 6, 2, 20, 0, 0, 0, newYork); !event.StartDate.Equal(want) {
+		t.Errorf("got start %s, want %s", event.StartDate, want)
+	}
+	if event.RRule != "FREQ=WEEKLY;COUNT=4" {
+		t.Errorf("got rule %q", event.RRule)
+	}
+	if event.Description != "Raid night" {
+		t.Errorf("got description %q, want the summary", event.Description)
+	}
+
+	wantExDates := []time.Time{
+		time.Date(2025, 6, 9, 20, 0, 0, 0, newYork),
+		time.Date(2025, 6, 16, 20, 0, 0, 0, newYork),
+		time.Date(2025, 6, 23, 20, 0, 0, 0, time.UTC),
+	}
+	if len(event.ExDates) != len(wantExDates) {
+		t.Fatalf("got exception dates %v, want %v", event.ExDates, wantExDates)
+	}
+	for i := range wantExDates {
+		if !event.ExDates[i].Equal(wantExDates[i]) {
+			t.Errorf("got exception date %s, want %s", event.ExDates[i], wantExDates[i])
+		}
+	}
+
+	if want := time.Date(2025, 7, 1, 20, 0, 0, 0, newYork); len(event.RDates) != 1 || !event.RDates[0].Equal(want) {
+		t.Errorf("got additional dates %v, want [%s]", event.RDates, want)
+	}
+}
+
+func TestImportICalEventsUID(t *testing.T) {
+	imported, errs := importICalEvents(parseTestCalendar(t,
+		[]string{
+			"UID:raid@example.com",
+			"SUMMARY:Raid night",
+			"DTSTART:20250602T200000Z",
+			"DURATION:PT1H",
+			"RRULE:FREQ=WEEKLY;COUNT=4",
+		},
+		[]string{
+			"UID:raid@example.com",
+			"RECURRENCE-ID:20250609T200000Z",
+			"SUMMARY:Raid night, heroic",
+			"DTSTART:20250609T210000Z",
+			"DURATION:PT1H",
+		},
+		[]string{
+			"UID:raid@example.com",
+			"RECURRENCE-ID:20250616T200000Z",
+			"STATUS:CANCELLED",
+		},
+		[]string{
+			"UID:raid@example.com",
+			"SUMMARY:Raid night again",
+			"DTSTART:20250602T200000Z",
+		},
+		[]string{
+			"UID:orphan@example.com",
+			"RECURRENCE-ID:20250609T200000Z",
+			"DTSTART:20250609T210000Z",
+		},
+		[]string{
+			"SUMMARY:No UID",
+			"DTSTART:20250602T200000Z",
+		},
+	))
+
+	if len(imported) != 1 || imported[0].UID != "raid@example.com" {
+		t.Fatalf("got events %+v, want only the first raid@example.com", imported)
+	}
+
+	wantErrs := map[string]map[string]string{
+		"raid@example.com":   {"uid": "must be unique in the calendar"},
+		"orphan@example.com": {"recurrence-id": "must belong to an event of the calendar"},
+		"":                   {"uid": "must be provided"},
+	}
+	if !maps.EqualFunc(errs, wantErrs, maps.Equal) {
+		t.Errorf("got errors %v, want %v", errs, wantErrs)
+	}
+
+	overrides := imported[0].Event.Overrides
+	if len(overrides) != 2 {
+		t.Fatalf("got overrides %+v, want the two modified occurrences", overrides)
+	}
+
+	moved := overrides[0]
+	if want := time.Date(2025, 6, 9, 20, 0, 0, 0, time.UTC); !moved.OriginalStart.Equal(want) {
+		t.Errorf("got the override of %s, want %s", moved.OriginalStart, want)
+	}
+	if want := time.Date(2025, 6, 9, 21, 0, 0, 0, time.UTC); moved.StartDate == nil || !moved.StartDate.Equal(want) {
+		t.Errorf("got the occurrence moved to %v, want %s", moved.StartDate, want)
+	}
+	if moved.Title == nil || *moved.Title != "Raid night, heroic" {
+		t.Errorf("got title %v, want the modified one", moved.Title)
+	}
+	if moved.Duration != nil {
+		t.Errorf("got duration %q, want the event's", *moved.Duration)
+	}
+
+	if !overrides[1].Cancelled {
+		t.Errorf("got override %+v, want it cancelled", overrides[1])
+	}
+}
+
+// fakeEventStore keeps the events by ID, failing like data.EventModel does
+// when there is none.
+type fakeEventStore map[uuid.UUID]data.Event
+
+func (f fakeEventStore) Get(ID uuid.UUID) (data.Event, error) {
+	event, ok := f[ID]
+	if !ok {
+		return data.Event{}, sql.ErrNoRows
+	}
+
+	return event, nil
+}
+
+func (f fakeEventStore) GetByICalUID(uid string) (data.Event, error) {
+	for _, event := range f {
+		if event.ICalUID == uid {
+			return event, nil
+		}
+	}
+
+	return data.Event{}, data.ErrRecordNotFound
+}
+
+func TestResolveImportedEvent(t *testing.T) {
+	imported := data.Event{
+		ID:          uuid.MustParse("a3c1e7d0-6d0e-4d36-8f0e-2a4b8c1d9e01"),
+		ICalUID:     "raid@example.com",
+		WebhookID:   uuid.MustParse("b7d2f8e1-7e1f-4e47-9a1f-3b5c9d2eaf12"),
+		RSVP:        true,
+		Capacity:    10,
+		CreatedDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	exported := data.Event{
+		ID:        uuid.MustParse("c8e3a9f2-8f2a-4f58-8b2a-4c6dae3fb023"),
+		IsActive:  true,
+		WebhookID: imported.WebhookID,
+	}
+	store := fakeEventStore{imported.ID: imported, exported.ID: exported}
+
+	webhookID := uuid.MustParse("d9f4baf3-9a3b-4a69-9c3b-5d7ebf4ac134")
+
+	tests := []struct {
+		name          string
+		uid           string
+		webhookID     uuid.UUID
+		wantAction    string
+		wantID        uuid.UUID
+		wantActive    bool
+		wantWebhookID uuid.UUID
+		wantICalUID   string
+	}{
+		{
+			name:          "imported before",
+			uid:           "raid@example.com",
+			wantAction:    "updated",
+			wantID:        imported.ID,
+			wantWebhookID: imported.WebhookID,
+			wantICalUID:   "raid@example.com",
+		},
+		{
+			name:          "imported before to another webhook",
+			uid:           "raid@example.com",
+			webhookID:     webhookID,
+			wantAction:    "updated",
+			wantID:        imported.ID,
+			wantWebhookID: webhookID,
+			wantICalUID:   "raid@example.com",
+		},
+		{
+			name:          "exported by us",
+			uid:           exported.ID.String(),
+			wantAction:    "updated",
+			wantID:        exported.ID,
+			wantActive:    true,
+			wantWebhookID: exported.WebhookID,
+		},
+		{
+			name:          "new",
+			uid:           "new@example.com",
+			webhookID:     webhookID,
+			wantAction:    "created",
+			wantActive:    true,
+			wantWebhookID: webhookID,
+			wantICalUID:   "new@example.com",
+		},
+		{
+			name:          "unknown ID",
+			uid:           "e0a5cba4-ab4c-4b7a-8d4c-6e8fca5bd245",
+			webhookID:     webhookID,
+			wantAction:    "created",
+			wantActive:    true,
+			wantWebhookID: webhookID,
+			wantICalUID:   "e0a5cba4-ab4c-4b7a-8d4c-6e8fca5bd245",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := importedEvent{UID: tt.uid, Event: data.Event{Title: "Raid night"}}
+
+			err := resolveImportedEvent(store, &event, tt.webhookID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := event.Event
+			if event.Action != tt.wantAction || got.ID != tt.wantID || got.IsActive != tt.wantActive ||
+				got.WebhookID != tt.wantWebhookID || got.ICalUID != tt.wantICalUID {
+				t.Errorf("got %s event %s, active %t, webhook %s, UID %q, want %s event %s, active %t, webhook %s, UID %q",
+					event.Action, got.ID, got.IsActive, got.WebhookID, got.ICalUID,
+					tt.wantAction, tt.wantID, tt.wantActive, tt.wantWebhookID, tt.wantICalUID)
+			}
+			if got.Title != "Raid night" {
+				t.Errorf("got title %q, want the imported one", got.Title)
+			}
+			if event.Action == "updated" && (got.RSVP != store[got.ID].RSVP || got.Capacity != store[got.ID].Capacity) {
+				t.Errorf("got RSVP %t and capacity %d, want the existing event's", got.RSVP, got.Capacity)
+			}
+		})
+	}
+}
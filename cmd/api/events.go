@@ -1,9 +1,10 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
-	duration "github.com/channelmeter/iso8601duration"
 	"github.com/google/uuid"
 	"net/http"
 	"time"
@@ -12,15 +13,23 @@ import (
 func (app *application) createEventHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		Title       string    `json:"title"`
-		Description string    `json:"description"`
-		Duration    string    `json:"duration"`
-		RRule       string    `json:"rrule"`
-		WebhookId   uuid.UUID `json:"webhook_id"`
+		Title       string                `json:"title"`
+		Description string                `json:"description"`
+		Duration    string                `json:"duration"`
+		RRule       string                `json:"rrule"`
+		StartDate   time.Time             `json:"start_date"`
+		Timezone    string                `json:"timezone"`
+		ExDates     []time.Time           `json:"exdates"`
+		RDates      []time.Time           `json:"rdates"`
+		WebhookId   uuid.UUID             `json:"webhook_id"`
+		Template    *data.MessageTemplate `json:"template"`
+		Mentions    data.Mentions         `json:"mentions"`
+		RSVP        bool                  `json:"rsvp"`
+		Capacity    int                   `json:"capacity"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
-		app.logger.Error("Unable to read JSON", err.Error())
+		app.logger.Error("Unable to read JSON", "error", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
@@ -30,21 +39,45 @@ func (app *application) createEventHandler(w http.ResponseWriter, r *http.Reques
 		Description: input.Description,
 		Duration:    input.Duration,
 		RRule:       input.RRule,
+		StartDate:   input.StartDate,
+		Timezone:    input.Timezone,
+		ExDates:     input.ExDates,
+		RDates:      input.RDates,
 		IsActive:    true,
 		WebhookID:   input.WebhookId,
+		Template:    input.Template,
+		Mentions:    input.Mentions,
+		RSVP:        input.RSVP,
+		Capacity:    input.Capacity,
+	}
+
+	if event.Timezone == "" {
+		event.Timezone = "UTC"
+	}
+	if event.Template != nil && event.Template.IsZero() {
+		event.Template = nil
 	}
 
 	v := validator.New()
-	if data.ValidateEvent(v, event); !v.Valid() {
+	data.ValidateEvent(v, event)
+	validateMessageTemplate(v, event.Template)
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
 	if err := app.models.Events.Insert(event); err != nil {
-		app.logger.Error("Unable to insert event", err.Error())
+		app.logger.Error("Unable to insert event", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+
+	if err := app.models.Occurrences.SetDates(event.ID, event.ExDates, event.RDates); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.refreshScheduledEvents(*event)
 }
 
 func (app *application) getEventHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,57 +89,52 @@ func (app *application) getEventHandler(w http.ResponseWriter, r *http.Request)
 
 	event, err := app.models.Events.Get(eventID)
 	if err != nil {
-		app.logger.Error("Unable to get event", err.Error())
+		app.logger.Error("Unable to get event", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	if err := app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil); err != nil {
-		app.logger.Error("Unable to write JSON", err.Error())
+		app.logger.Error("Unable to write JSON", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
+// getAllEventsHandler returns a page of the events. Their occurrences are
+// expanded by the occurrences endpoint.
 func (app *application) getAllEventsHandler(w http.ResponseWriter, r *http.Request) {
-	events, err := app.models.Events.GetAll()
-	if err != nil {
-		app.logger.Error("Unable to get all events", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	var input struct {
+		Search string
+		Tags   []string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Search = app.readString(qs, "search", "")
+	input.Tags = app.readCSV(qs, "tags", []string{})
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "title")
+	input.Filters.SortSafelist = []string{"id", "title", "created_date", "updated_date", "-id", "-title", "-created_date", "-updated_date"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	var eventInstances []data.EventInstance
-	for _, event := range events {
-		upcoming, err := ParseRRule(event.RRule)
-		if err != nil {
-			app.logger.Error("Unable to parse RRule", err.Error())
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-		now := time.Now()
-		firstDayMonth := now.AddDate(0, 0, -now.Day()+1)
-		lastDayMonth := now.AddDate(0, 1, -now.Day())
-		for _, u := range upcoming.Between(firstDayMonth, lastDayMonth, true) {
-			perEventDuration, err := duration.FromString(event.Duration)
-			if err != nil {
-				app.logger.Error("Unable to parse duration", err.Error())
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-			var instance data.EventInstance
-			instance.EventID = event.ID
-			instance.Title = event.Title
-			instance.Description = event.Description
-			instance.Duration = event.Duration
-			instance.StartDate = u
-			instance.EndDate = u.Add(perEventDuration.ToDuration())
-
-			eventInstances = append(eventInstances, instance)
-		}
+
+	events, metadata, err := app.models.Events.GetAll(input.Search, input.Tags, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	if err := app.writeJSON(w, http.StatusOK, envelope{"events": eventInstances}, nil); err != nil {
-		app.logger.Error("Unable to write JSON", err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 	}
 }
 
@@ -117,13 +145,41 @@ func (app *application) deleteEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The jobs and the scheduled events go along with the event, the
+	// messages they mirror it in are looked up first.
+	announced, err := app.announcedJobs(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	scheduled, err := app.models.Scheduled.GetAllForEvent(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.models.Events.Delete(eventID)
 	if err != nil {
-		app.logger.Error("Unable to delete event", err.Error())
+		app.logger.Error("Unable to delete event", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	app.reviseAnnouncements(event, announced, true)
+	app.removeScheduledEvents(event, scheduled)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -135,16 +191,24 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var input struct {
-		Title       string    `json:"title,omitempty"`
-		Description string    `json:"description,omitempty"`
-		Duration    string    `json:"duration,omitempty"`
-		RRule       string    `json:"rrule,omitempty"`
-		IsActive    bool      `json:"is_active,omitempty"`
-		WebhookId   uuid.UUID `json:"webhook_id,omitempty"`
+		Title       string                `json:"title,omitempty"`
+		Description string                `json:"description,omitempty"`
+		Duration    string                `json:"duration,omitempty"`
+		RRule       string                `json:"rrule,omitempty"`
+		StartDate   time.Time             `json:"start_date,omitempty"`
+		Timezone    string                `json:"timezone,omitempty"`
+		ExDates     *[]time.Time          `json:"exdates,omitempty"`
+		RDates      *[]time.Time          `json:"rdates,omitempty"`
+		IsActive    *bool                 `json:"is_active,omitempty"`
+		WebhookId   uuid.UUID             `json:"webhook_id,omitempty"`
+		Template    *data.MessageTemplate `json:"template,omitempty"`
+		Mentions    *data.Mentions        `json:"mentions,omitempty"`
+		RSVP        *bool                 `json:"rsvp,omitempty"`
+		Capacity    *int                  `json:"capacity,omitempty"`
 	}
 
 	if err := app.readJSON(w, r, &input); err != nil {
-		app.logger.Error("Unable to read JSON", err.Error())
+		app.logger.Error("Unable to read JSON", "error", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
@@ -154,6 +218,14 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// The occurrences announced are looked up before the update changes
+	// their duration.
+	announced, err := app.announcedJobs(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	if input.Title != "" {
 		event.Title = input.Title
 	}
@@ -166,30 +238,296 @@ func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 	if input.RRule != "" {
 		event.RRule = input.RRule
 	}
-	if input.IsActive != event.IsActive {
-		event.IsActive = input.IsActive
+	if !input.StartDate.IsZero() {
+		event.StartDate = input.StartDate
+	}
+	if input.Timezone != "" {
+		event.Timezone = input.Timezone
+	}
+	if input.ExDates != nil {
+		event.ExDates = *input.ExDates
+	}
+	if input.RDates != nil {
+		event.RDates = *input.RDates
+	}
+	if input.IsActive != nil {
+		event.IsActive = *input.IsActive
 	}
 	if input.WebhookId != uuid.Nil {
 		event.WebhookID = input.WebhookId
 	}
+	// An empty template goes back to the webhook's or the default one.
+	if input.Template != nil {
+		event.Template = input.Template
+		if input.Template.IsZero() {
+			event.Template = nil
+		}
+	}
+	if input.Mentions != nil {
+		event.Mentions = *input.Mentions
+	}
+	if input.RSVP != nil {
+		event.RSVP = *input.RSVP
+	}
+	if input.Capacity != nil {
+		event.Capacity = *input.Capacity
+	}
+
+	v := validator.New()
+	data.ValidateEvent(v, &event)
+	validateMessageTemplate(v, event.Template)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
 	if err := app.models.Events.Update(&event); err != nil {
-		app.logger.Error("Unable to update event", err.Error())
+		app.logger.Error("Unable to update event", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+
+	if err := app.models.Occurrences.SetDates(event.ID, event.ExDates, event.RDates); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Drop the occurrences that have not run yet, the scheduler materializes
+	// them again from the updated rule on its next pass.
+	if err := app.models.Jobs.DeletePendingForEvent(event.ID); err != nil {
+		app.logger.Error("Unable to reset pending jobs", "error", err)
+	}
+
+	app.reviseAnnouncements(event, announced, false)
+	app.refreshScheduledEvents(event)
 }
 
 func (app *application) getActiveEventsHandler(w http.ResponseWriter, r *http.Request) {
 	events, err := app.models.Events.GetActiveEvents()
 	if err != nil {
-		app.logger.Error("Unable to get active events", err.Error())
+		app.logger.Error("Unable to get active events", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	if err := app.writeJSON(w, http.StatusOK, envelope{"active_events": events}, nil); err != nil {
-		app.logger.Error("Unable to write JSON", err.Error())
+		app.logger.Error("Unable to write JSON", "error", err)
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) addEventTagHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tagID, err := app.readUUIDParam(r, "tag_id")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tag, err := app.models.Tags.GetByID(tagID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if tag == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Tags.AddForEvent(eventID, tagID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeEventTagHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tagID, err := app.readUUIDParam(r, "tag_id")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tags.DeleteForEvent(eventID, tagID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addEventWebhookHandler announces the event on another webhook, on top of
+// its own. Jobs are materialized for it on the next scheduler run.
+func (app *application) addEventWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhookID, err := app.readUUIDParam(r, "webhook_id")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	_, err = app.models.Webhooks.GetByID(webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Webhooks.AddForEvent(eventID, webhookID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeEventWebhookHandler stops announcing the event on one of the
+// webhooks added to it, the pending jobs for that webhook are dropped.
+func (app *application) removeEventWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhookID, err := app.readUUIDParam(r, "webhook_id")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Webhooks.DeleteForEvent(eventID, webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Jobs.DeletePendingForEvent(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// saveEvent inserts the event when it has no ID yet and updates it otherwise,
+// then stores its exception dates and its overrides.
+func (app *application) saveEvent(event *data.Event) error {
+	var err error
+	if event.ID == uuid.Nil {
+		err = app.models.Events.Insert(event)
+	} else {
+		err = app.models.Events.Update(event)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Occurrences.SetDates(event.ID, event.ExDates, event.RDates)
+	if err != nil {
+		return err
+	}
+
+	for i := range event.Overrides {
+		event.Overrides[i].EventID = event.ID
+		err = app.models.Occurrences.UpsertOverride(&event.Overrides[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
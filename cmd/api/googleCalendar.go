@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// googleEventsLister lists a page of the events of a Google Calendar, the
+// ones changed since syncToken when it is set. It is what the source needs
+// from *calendar.Service, so a fake can stand in for the API.
+type googleEventsLister interface {
+	List(ctx context.Context, calendarID, syncToken, pageToken string) (*calendar.Events, error)
+}
+
+// googleEventsService lists the events through the Calendar API.
+type googleEventsService struct {
+	service *calendar.Service
+}
+
+func (s googleEventsService) List(ctx context.Context, calendarID, syncToken, pageToken string) (*calendar.Events, error) {
+	call := s.service.Events.List(calendarID).
+		SingleEvents(false).
+		ShowDeleted(true).
+		Context(ctx)
+
+	if syncToken != "" {
+		call.SyncToken(syncToken)
+	}
+	if pageToken != "" {
+		call.PageToken(pageToken)
+	}
+
+	return call.Do()
+}
+
+// googleCalendarSource reads the events of a Google Calendar through a
+// service account the calendar is shared with.
+type googleCalendarSource struct {
+	events     googleEventsLister
+	calendarID string
+}
+
+func newGoogleCalendarSource(ctx context.Context, credentialsFile, calendarID string) (*googleCalendarSource, error) {
+	service, err := calendar.NewService(ctx, option.WithCredentialsFile(credentialsFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to google services: %w", err)
+	}
+
+	return &googleCalendarSource{
+		events:     googleEventsService{service: service},
+		calendarID: calendarID,
+	}, nil
+}
+
+func (g *googleCalendarSource) Name() string {
+	return "google"
+}
+
+// Changes lists every event on a full synchronization, then only the ones
+// changed since the sync token given as cursor. Recurring events are listed
+// once with their recurrence, and the instances modified or cancelled in
+// Google Calendar as separate changes.
+func (g *googleCalendarSource) Changes(ctx context.Context, cursor string) ([]CalendarChange, string, error) {
+	var items []*calendar.Event
+	var defaultTimezone, pageToken string
+
+	for {
+		page, err := g.events.List(ctx, g.calendarID, cursor, pageToken)
+		if err != nil {
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusGone {
+				return nil, "", ErrCursorExpired
+			}
+			return nil, "", err
+		}
+
+		if page.TimeZone != "" {
+			defaultTimezone = page.TimeZone
+		}
+		items = append(items, page.Items...)
+
+		if page.NextPageToken == "" {
+			return googleChanges(items, defaultTimezone), page.NextSyncToken, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// googleChanges converts the listed items to changes. The items which cannot
+// be read become changes carrying the error, for the others to still apply.
+func googleChanges(items []*calendar.Event, defaultTimezone string) []CalendarChange {
+	changes := make([]CalendarChange, 0, len(items))
+
+	for _, item := range items {
+		change := CalendarChange{
+			ExternalID:  item.Id,
+			RecurringID: item.RecurringEventId,
+			Cancelled:   item.Status == "cancelled",
+		}
+
+		// Deleted events only carry their IDs, and their original start when
+		// they are an instance of a recurring event.
+		var err error
+		switch {
+		case change.RecurringID != "":
+			change.OriginalStart, err = googleOriginalStart(item, defaultTimezone)
+			if err == nil && !change.Cancelled {
+				change.Event, err = googleEvent(item, defaultTimezone)
+			}
+		case !change.Cancelled:
+			change.Event, err = googleEvent(item, defaultTimezone)
+		}
+		if err != nil {
+			change.Err = fmt.Errorf("event %s: %w", item.Id, err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func googleEvent(item *calendar.Event, defaultTimezone string) (data.Event, error) {
+	event := data.Event{
+		ExternalID:  item.Id,
+		Title:       item.Summary,
+		Description: item.Description,
+		RRule:       "FREQ=DAILY;COUNT=1",
+		Timezone:    "UTC",
+		ExDates:     []time.Time{},
+		RDates:      []time.Time{},
+		Overrides:   []data.OccurrenceOverride{},
+	}
+
+	if event.Description == "" {
+		event.Description = event.Title
+	}
+
+	if item.Start != nil && item.Start.TimeZone != "" {
+		event.Timezone = item.Start.TimeZone
+	} else if defaultTimezone != "" {
+		event.Timezone = defaultTimezone
+	}
+
+	loc, err := time.LoadLocation(event.Timezone)
+	if err != nil {
+		return event, err
+	}
+
+	start, err := googleTime(item.Start, loc)
+	if err != nil {
+		return event, err
+	}
+	end, err := googleTime(item.End, loc)
+	if err != nil {
+		return event, err
+	}
+
+	event.StartDate = start
+	event.Duration = isoDuration(end.Sub(start))
+
+	// The recurrence holds RRULE, EXDATE and RDATE lines as in iCalendar.
+	for _, line := range item.Recurrence {
+		p, err := parseICalProperty(line)
+		if err != nil {
+			return event, err
+		}
+
+		switch p.Name {
+		case "RRULE":
+			event.RRule = p.Value
+		case "EXDATE", "RDATE":
+			if p.Params["TZID"] == "" {
+				p.Params["TZID"] = event.Timezone
+			}
+
+			dates, _, err := icalTimes(p)
+			if err != nil {
+				return event, err
+			}
+
+			if p.Name == "EXDATE" {
+				event.ExDates = append(event.ExDates, dates...)
+			} else {
+				event.RDates = append(event.RDates, dates...)
+			}
+		}
+	}
+
+	slices.SortFunc(event.ExDates, time.Time.Compare)
+	slices.SortFunc(event.RDates, time.Time.Compare)
+
+	return event, nil
+}
+
+// googleOriginalStart returns the start the recurrence gives to the instance
+// of a recurring event.
+func googleOriginalStart(item *calendar.Event, defaultTimezone string) (time.Time, error) {
+	loc := time.UTC
+	if dt := item.OriginalStartTime; dt != nil && (dt.TimeZone != "" || defaultTimezone != "") {
+		name := dt.TimeZone
+		if name == "" {
+			name = defaultTimezone
+		}
+
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return googleTime(item.OriginalStartTime, loc)
+}
+
+// googleTime returns the time of an event date, all-day dates are midnight
+// in loc.
+func googleTime(dt *calendar.EventDateTime, loc *time.Location) (time.Time, error) {
+	switch {
+	case dt == nil:
+		return time.Time{}, errors.New("missing date")
+	case dt.DateTime != "":
+		t, err := time.Parse(time.RFC3339, dt.DateTime)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.In(loc), nil
+	case dt.Date != "":
+		return time.ParseInLocation(time.DateOnly, dt.Date, loc)
+	default:
+		return time.Time{}, errors.New("missing date")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeEventsLister serves the pages of a calendar keyed by sync token and
+// page token, and records the calls made to it.
+type fakeEventsLister struct {
+	pages map[[2]string]*calendar.Events
+	// expired are the sync tokens Google no longer accepts.
+	expired map[string]bool
+	calls   [][2]string
+}
+
+func (f *fakeEventsLister) List(ctx context.Context, calendarID, syncToken, pageToken string) (*calendar.Events, error) {
+	key := [2]string{syncToken, pageToken}
+	f.calls = append(f.calls, key)
+
+	if f.expired[syncToken] {
+		return nil, &googleapi.Error{Code: http.StatusGone, Message: "Sync token is no longer valid"}
+	}
+
+	page, ok := f.pages[key]
+	if !ok {
+		return nil, fmt.Errorf("unexpected call with sync token %q and page token %q", syncToken, pageToken)
+	}
+
+	return page, nil
+}
+
+func newTestApplication() *application {
+	return &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func weeklyItem(id, summary string) *calendar.Event {
+	return &calendar.Event{
+		Id:         id,
+		Status:     "confirmed",
+		Summary:    summary,
+		Start:      &calendar.EventDateTime{DateTime: "2025-06-02T20:00:00+02:00", TimeZone: "Europe/Paris"},
+		End:        &calendar.EventDateTime{DateTime: "2025-06-02T21:30:00+02:00", TimeZone: "Europe/Paris"},
+		Recurrence: []string{"RRULE:FREQ=WEEKLY;BYDAY=MO"},
+	}
+}
+
+func TestGoogleChangesSyncToken(t *testing.T) {
+	lister := &fakeEventsLister{
+		pages: map[[2]string]*calendar.Events{
+			{"", ""}: {
+				TimeZone:      "Europe/Paris",
+				Items:         []*calendar.Event{weeklyItem("raid", "Raid night")},
+				NextPageToken: "page-2",
+			},
+			{"", "page-2"}: {
+				Items:         []*calendar.Event{weeklyItem("quiz", "Quiz")},
+				NextSyncToken: "token-1",
+			},
+			{"token-1", ""}: {
+				Items:         []*calendar.Event{weeklyItem("quiz", "Quiz night")},
+				NextSyncToken: "token-2",
+			},
+		},
+	}
+	source := &googleCalendarSource{events: lister, calendarID: "primary"}
+
+	changes, next, err := source.Changes(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "token-1" {
+		t.Errorf("got cursor %q after the full synchronization, want token-1", next)
+	}
+	if len(changes) != 2 || changes[0].ExternalID != "raid" || changes[1].ExternalID != "quiz" {
+		t.Fatalf("got changes %+v, want raid and quiz from both pages", changes)
+	}
+
+	event := changes[0].Event
+	if event.RRule != "FREQ=WEEKLY;BYDAY=MO" || event.Timezone != "Europe/Paris" || event.Duration != "PT1H30M" {
+		t.Errorf("got event %+v, want the weekly rule in Europe/Paris lasting PT1H30M", event)
+	}
+
+	changes, next, err = source.Changes(context.Background(), "token-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "token-2" {
+		t.Errorf("got cursor %q after the incremental synchronization, want token-2", next)
+	}
+	if len(changes) != 1 || changes[0].Event.Title != "Quiz night" {
+		t.Errorf("got changes %+v, want only the renamed quiz", changes)
+	}
+
+	want := [][2]string{{"", ""}, {"", "page-2"}, {"token-1", ""}}
+	if fmt.Sprint(lister.calls) != fmt.Sprint(want) {
+		t.Errorf("got calls %v, want %v", lister.calls, want)
+	}
+}
+
+func TestGoogleChangesExpiredSyncToken(t *testing.T) {
+	lister := &fakeEventsLister{
+		pages: map[[2]string]*calendar.Events{
+			{"", ""}: {
+				Items:         []*calendar.Event{weeklyItem("raid", "Raid night")},
+				NextSyncToken: "token-2",
+			},
+		},
+		expired: map[string]bool{"token-1": true},
+	}
+	source := &googleCalendarSource{events: lister, calendarID: "primary"}
+
+	_, _, err := source.Changes(context.Background(), "token-1")
+	if !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("got error %v, want ErrCursorExpired", err)
+	}
+
+	app := newTestApplication()
+
+	changes, next, err := app.sourceChanges(context.Background(), source, "token-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "token-2" || len(changes) != 1 || changes[0].ExternalID != "raid" {
+		t.Errorf("got %d changes and cursor %q, want the full synchronization with token-2", len(changes), next)
+	}
+	if last := lister.calls[len(lister.calls)-1]; last != [2]string{"", ""} {
+		t.Errorf("got last call %v, want a full synchronization", last)
+	}
+}
+
+func TestGoogleChangesCancellations(t *testing.T) {
+	items := []*calendar.Event{
+		{Id: "raid", Status: "cancelled"},
+		{
+			Id:                "quiz_20250609T180000Z",
+			Status:            "cancelled",
+			RecurringEventId:  "quiz",
+			OriginalStartTime: &calendar.EventDateTime{DateTime: "2025-06-09T20:00:00+02:00", TimeZone: "Europe/Paris"},
+		},
+	}
+
+	changes := googleChanges(items, "UTC")
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+
+	if c := changes[0]; !c.Cancelled || c.RecurringID != "" || c.Err != nil || c.Event.Title != "" {
+		t.Errorf("got %+v, want the cancellation of the raid without an event", c)
+	}
+
+	c := changes[1]
+	if !c.Cancelled || c.RecurringID != "quiz" || c.Err != nil {
+		t.Fatalf("got %+v, want the cancellation of an instance of the quiz", c)
+	}
+	if want := time.Date(2025, 6, 9, 18, 0, 0, 0, time.UTC); !c.OriginalStart.Equal(want) {
+		t.Errorf("got original start %s, want %s", c.OriginalStart, want)
+	}
+
+	override := instanceOverride(data.Event{Title: "Quiz"}, c)
+	if !override.Cancelled || override.Title != nil || override.StartDate != nil {
+		t.Errorf("got override %+v, want a bare cancellation", override)
+	}
+}
+
+func TestGoogleChangesInstanceOverride(t *testing.T) {
+	moved := &calendar.Event{
+		Id:                "quiz_20250609T180000Z",
+		Status:            "confirmed",
+		RecurringEventId:  "quiz",
+		Summary:           "Quiz finals",
+		OriginalStartTime: &calendar.EventDateTime{DateTime: "2025-06-09T20:00:00+02:00", TimeZone: "Europe/Paris"},
+		Start:             &calendar.EventDateTime{DateTime: "2025-06-09T21:00:00+02:00", TimeZone: "Europe/Paris"},
+		End:               &calendar.EventDateTime{DateTime: "2025-06-09T22:30:00+02:00", TimeZone: "Europe/Paris"},
+	}
+
+	changes := googleChanges([]*calendar.Event{moved}, "UTC")
+	if len(changes) != 1 || changes[0].Err != nil {
+		t.Fatalf("got changes %+v, want the moved instance", changes)
+	}
+
+	event := googleTestEvent(t, weeklyItem("quiz", "Quiz"))
+
+	override := instanceOverride(event, changes[0])
+	if override.Cancelled {
+		t.Error("got a cancelled override, want a moved one")
+	}
+	if override.Title == nil || *override.Title != "Quiz finals" {
+		t.Errorf("got title %v, want Quiz finals", override.Title)
+	}
+	if want := time.Date(2025, 6, 9, 19, 0, 0, 0, time.UTC); override.StartDate == nil || !override.StartDate.Equal(want) {
+		t.Errorf("got start %v, want %s", override.StartDate, want)
+	}
+	if override.Duration != nil {
+		t.Errorf("got duration %q, want the event's", *override.Duration)
+	}
+}
+
+func TestGoogleChangesUnreadableItem(t *testing.T) {
+	items := []*calendar.Event{
+		{Id: "broken", Status: "confirmed", Summary: "No dates"},
+		weeklyItem("raid", "Raid night"),
+	}
+
+	changes := googleChanges(items, "UTC")
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	if changes[0].Err == nil {
+		t.Error("got no error for the event without dates")
+	}
+	if changes[1].Err != nil || changes[1].Event.Title != "Raid night" {
+		t.Errorf("got %+v, want the raid read despite the broken event", changes[1])
+	}
+}
+
+func googleTestEvent(t *testing.T, item *calendar.Event) data.Event {
+	t.Helper()
+
+	event, err := googleEvent(item, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
 	"github.com/google/uuid"
 	"github.com/teambition/rrule-go"
@@ -18,15 +19,30 @@ import (
 )
 
 func (app *application) readIDParam(r *http.Request) (uuid.UUID, error) {
+	return app.readUUIDParam(r, "id")
+}
+
+func (app *application) readUUIDParam(r *http.Request, name string) (uuid.UUID, error) {
 	params := httprouter.ParamsFromContext(r.Context())
-	id, err := uuid.Parse(params.ByName("id"))
+	id, err := uuid.Parse(params.ByName(name))
 	if err != nil || id == uuid.Nil {
-		return uuid.Nil, errors.New("invalid id parameter")
+		return uuid.Nil, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
 }
 
+// readStartParam reads the :start parameter identifying an occurrence of an
+// event, the RFC 3339 timestamp its rule starts it at.
+func (app *application) readStartParam(r *http.Request) (time.Time, error) {
+	start, err := time.Parse(time.RFC3339, httprouter.ParamsFromContext(r.Context()).ByName("start"))
+	if err != nil {
+		return time.Time{}, errors.New("invalid start parameter, must be an RFC 3339 timestamp")
+	}
+
+	return start, nil
+}
+
 type envelope map[string]any
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
@@ -101,19 +117,50 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 	return nil
 }
 
-func ParseRRule(s string) (*rrule.RRule, error) {
-	// ensure RRULE: prefix
-	if !strings.HasPrefix(strings.ToUpper(s), "RRULE:") {
-		s = "RRULE:" + s
+// ParseRRule parses the rule and anchors it on dtstart. The location of
+// dtstart is the one the rule is expanded in, so local times such as
+// "every Friday at 20:00" stay put across DST changes.
+func ParseRRule(s string, dtstart time.Time) (*rrule.RRule, error) {
+	opt, err := rrule.StrToROptionInLocation(s, dtstart.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	opt.Dtstart = dtstart
+
+	return rrule.NewRRule(*opt)
+}
+
+// eventRule returns the recurrence set of the event: its rule anchored on
+// its stored start date in its own time zone, the extra RDATEs, minus the
+// EXDATEs and the cancelled occurrences.
+func eventRule(event data.Event) (*rrule.Set, error) {
+	loc, err := time.LoadLocation(event.Timezone)
+	if err != nil {
+		return nil, err
 	}
 
-	// if no DTSTART, append one at UTC now
-	if !strings.Contains(strings.ToUpper(s), "DTSTART=") {
-		dt := time.Now().UTC().Format("20060102T150405Z")
-		s = s + ";DTSTART=" + dt
+	rule, err := ParseRRule(event.RRule, event.StartDate.In(loc))
+	if err != nil {
+		return nil, err
 	}
 
-	return rrule.StrToRRule(s)
+	set := &rrule.Set{}
+	set.RRule(rule)
+
+	for _, date := range event.RDates {
+		set.RDate(date.In(loc))
+	}
+	for _, date := range event.ExDates {
+		set.ExDate(date.In(loc))
+	}
+	for _, override := range event.Overrides {
+		if override.Cancelled {
+			set.ExDate(override.OriginalStart.In(loc))
+		}
+	}
+
+	return set, nil
 }
 
 func (app *application) readString(qs url.Values, key string, defaultValue string) string {
@@ -152,6 +199,27 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readTime(qs url.Values, key string, defaultValue time.Time, loc *time.Location, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t
+	}
+
+	t, err = time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		return defaultValue
+	}
+
+	return t
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
@@ -161,10 +229,21 @@ func (app *application) background(fn func()) {
 
 		defer func() {
 			if err := recover(); err != nil {
-				app.logger.Error("Error while running background tasks", fmt.Errorf("%s", err), nil)
+				app.logger.Error("Error while running background tasks", "error", fmt.Errorf("%s", err))
 			}
 		}()
 
 		fn()
 	}()
 }
+
+// redactURLError drops the URL from the error of an HTTP request, as the
+// URLs of webhooks hold their secrets.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request: %w", urlErr.Op, urlErr.Err)
+	}
+
+	return err
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+)
+
+const (
+	icalDateTimeFormat    = "20060102T150405"
+	icalUTCDateTimeFormat = "20060102T150405Z"
+	icalMaxLineLength     = 75
+	// icalTimezoneYears is how many years from now the VTIMEZONE components
+	// list the transitions of their time zone.
+	icalTimezoneYears = 10
+)
+
+// icalWriter serializes events as an RFC 5545 calendar. The first write error
+// is kept and every later write is skipped, so callers only check it once.
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+// writeCalendar writes the events as a VCALENDAR with one VEVENT per event,
+// plus one per modified occurrence identified by its RECURRENCE-ID. The
+// events' UIDs are their IDs, so they stay stable across exports. Times are
+// written with the IANA name of the event's time zone as TZID, which a
+// VTIMEZONE component defines.
+func writeCalendar(w io.Writer, name string, events []data.Event) error {
+	iw := &icalWriter{w: w}
+
+	locs := make([]*time.Location, len(events))
+	for i, event := range events {
+		loc, err := time.LoadLocation(event.Timezone)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", event.ID, err)
+		}
+		locs[i] = loc
+	}
+
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//GoEventBot//" + icalText(name) + "//EN")
+	iw.line("CALSCALE:GREGORIAN")
+	iw.line("METHOD:PUBLISH")
+	iw.line("X-WR-CALNAME:" + icalText(name))
+
+	for _, zone := range icalTimezones(events, locs, time.Now()) {
+		iw.timezone(zone)
+	}
+
+	for i, event := range events {
+		iw.event(event, locs[i])
+	}
+
+	iw.line("END:VCALENDAR")
+
+	return iw.err
+}
+
+// icalTimezone is a time zone the events of a calendar are written in, and
+// the span of time its VTIMEZONE covers.
+type icalTimezone struct {
+	loc        *time.Location
+	start, end time.Time
+}
+
+// icalTimezones returns the time zones of the events other than UTC, in the
+// order they first appear. Each one covers the dates of its events, and the
+// icalTimezoneYears years after now for the recurring events.
+func icalTimezones(events []data.Event, locs []*time.Location, now time.Time) []icalTimezone {
+	var zones []icalTimezone
+	index := map[string]int{}
+
+	for i, event := range events {
+		loc := locs[i]
+		if loc == time.UTC {
+			continue
+		}
+
+		dates := append([]time.Time{event.StartDate}, event.RDates...)
+
+		j, ok := index[loc.String()]
+		if !ok {
+			j = len(zones)
+			index[loc.String()] = j
+			zones = append(zones, icalTimezone{loc: loc, start: dates[0], end: now.AddDate(icalTimezoneYears, 0, 0)})
+		}
+
+		for _, date := range dates {
+			if date.Before(zones[j].start) {
+				zones[j].start = date
+			}
+			if date.After(zones[j].end) {
+				zones[j].end = date
+			}
+		}
+	}
+
+	return zones
+}
+
+// timezone writes the VTIMEZONE of zone with one observance per transition
+// of the time zone, as the Go time zone database has no rules to write as
+// RRULEs. The first observance is the offset in effect at the start of zone.
+func (iw *icalWriter) timezone(zone icalTimezone) {
+	iw.line("BEGIN:VTIMEZONE")
+	iw.line("TZID:" + zone.loc.String())
+
+	t := zone.start.In(zone.loc)
+	start, end := t.ZoneBounds()
+	if start.IsZero() {
+		// The offset never changed before.
+		_, offset := t.Zone()
+		iw.observance(t, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), offset)
+	} else {
+		iw.transition(start)
+	}
+
+	for !end.IsZero() && !end.After(zone.end) {
+		iw.transition(end)
+		_, end = end.ZoneBounds()
+	}
+
+	iw.line("END:VTIMEZONE")
+}
+
+// transition writes the observance the time zone of t changes to at t.
+func (iw *icalWriter) transition(t time.Time) {
+	_, from := t.Add(-time.Second).Zone()
+
+	iw.observance(t, t.In(time.FixedZone("", from)), from)
+}
+
+// observance writes the STANDARD or DAYLIGHT component of the offset in
+// effect at t, which starts at the local time onset and follows from.
+func (iw *icalWriter) observance(t, onset time.Time, from int) {
+	component := "STANDARD"
+	if t.IsDST() {
+		component = "DAYLIGHT"
+	}
+
+	name, offset := t.Zone()
+
+	iw.line("BEGIN:" + component)
+	iw.line("DTSTART:" + onset.Format(icalDateTimeFormat))
+	iw.line("TZOFFSETFROM:" + icalOffset(from))
+	iw.line("TZOFFSETTO:" + icalOffset(offset))
+	iw.line("TZNAME:" + icalText(name))
+	iw.line("END:" + component)
+}
+
+func (iw *icalWriter) event(event data.Event, loc *time.Location) {
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + event.ID.String())
+	iw.line("DTSTAMP:" + event.UpdatedDate.UTC().Format(icalUTCDateTimeFormat))
+	iw.line("CREATED:" + event.CreatedDate.UTC().Format(icalUTCDateTimeFormat))
+	iw.line("LAST-MODIFIED:" + event.UpdatedDate.UTC().Format(icalUTCDateTimeFormat))
+	iw.line("DTSTART" + icalDateTime(event.StartDate, loc))
+	iw.line("DURATION:" + event.Duration)
+	if event.RRule != "" {
+		iw.line("RRULE:" + strings.TrimPrefix(strings.ToUpper(event.RRule), "RRULE:"))
+	}
+	for _, date := range event.RDates {
+		iw.line("RDATE" + icalDateTime(date, loc))
+	}
+	for _, date := range event.ExDates {
+		iw.line("EXDATE" + icalDateTime(date, loc))
+	}
+	for _, override := range event.Overrides {
+		if override.Cancelled {
+			iw.line("EXDATE" + icalDateTime(override.OriginalStart, loc))
+		}
+	}
+	iw.line("SUMMARY:" + icalText(event.Title))
+	iw.line("DESCRIPTION:" + icalText(event.Description))
+	iw.categories(event.Tags)
+	iw.line("END:VEVENT")
+
+	for _, override := range event.Overrides {
+		if override.Cancelled {
+			continue
+		}
+
+		instance, err := newEventInstance(event, override.OriginalStart)
+		if err != nil {
+			iw.err = fmt.Errorf("event %s: %w", event.ID, err)
+			return
+		}
+
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:" + event.ID.String())
+		iw.line("DTSTAMP:" + override.UpdatedDate.UTC().Format(icalUTCDateTimeFormat))
+		iw.line("RECURRENCE-ID" + icalDateTime(override.OriginalStart, loc))
+		iw.line("DTSTART" + icalDateTime(instance.StartDate, loc))
+		iw.line("DURATION:" + instance.Duration)
+		iw.line("SUMMARY:" + icalText(instance.Title))
+		iw.line("DESCRIPTION:" + icalText(instance.Description))
+		iw.categories(event.Tags)
+		iw.line("END:VEVENT")
+	}
+}
+
+func (iw *icalWriter) categories(tags []data.Tag) {
+	if len(tags) == 0 {
+		return
+	}
+
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = icalText(tag.Name)
+	}
+
+	iw.line("CATEGORIES:" + strings.Join(names, ","))
+}
+
+// line writes a content line terminated by CRLF, folded so that no line is
+// longer than 75 octets without splitting a UTF-8 sequence.
+func (iw *icalWriter) line(s string) {
+	if iw.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	limit := icalMaxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// The leading space of a continuation line counts in its length.
+		limit = icalMaxLineLength - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+
+	_, iw.err = io.WriteString(iw.w, b.String())
+}
+
+// icalDateTime returns the parameters and value of a DATE-TIME property, in
+// UTC form for UTC events and with a TZID otherwise.
+func icalDateTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + t.UTC().Format(icalUTCDateTimeFormat)
+	}
+
+	return ";TZID=" + loc.String() + ":" + t.In(loc).Format(icalDateTimeFormat)
+}
+
+// icalOffset returns the UTC-OFFSET value of offset seconds east of UTC.
+func icalOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	s := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+
+	return s
+}
+
+// icalText escapes a TEXT value.
+func icalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const icalDateFormat = "20060102"
+
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icalComponent is a VEVENT with its properties, the components nested in it
+// such as VALARM are dropped.
+type icalComponent struct {
+	Properties []icalProperty
+}
+
+func (c icalComponent) get(name string) (icalProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return icalProperty{}, false
+}
+
+func (c icalComponent) text(name string) string {
+	p, _ := c.get(name)
+	return icalUnescape(p.Value)
+}
+
+func (c icalComponent) all(name string) []icalProperty {
+	var props []icalProperty
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+
+	return props
+}
+
+// parseCalendar returns the VEVENTs of an RFC 5545 calendar.
+func parseCalendar(r io.Reader) ([]icalComponent, error) {
+	lines, err := icalUnfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []icalComponent
+	var current *icalComponent
+	var stack []string
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		p, err := parseICalProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			name := strings.ToUpper(p.Value)
+			if name == "VEVENT" && len(stack) == 1 && stack[0] == "VCALENDAR" {
+				current = &icalComponent{}
+			}
+			stack = append(stack, name)
+		case "END":
+			name := strings.ToUpper(p.Value)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+			if name == "VEVENT" && current != nil && len(stack) == 1 {
+				events = append(events, *current)
+				current = nil
+			}
+		default:
+			if current != nil && len(stack) == 2 {
+				current.Properties = append(current.Properties, p)
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("unterminated %s component", stack[len(stack)-1])
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("calendar must contain at least one VEVENT")
+	}
+
+	return events, nil
+}
+
+// icalUnfold returns the content lines, with the folded ones joined back.
+func icalUnfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1_048_576)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseICalProperty splits a content line into its name, parameters and
+// value. Parameter values may be quoted to contain ":" or ";".
+func parseICalProperty(line string) (icalProperty, error) {
+	p := icalProperty{Params: make(map[string]string)}
+
+	var parts []string
+	inQuotes := false
+	start := 0
+	colon := -1
+
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch c := line[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ';' && !inQuotes:
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case c == ':' && !inQuotes:
+			parts = append(parts, line[start:i])
+			colon = i
+		}
+	}
+
+	if colon < 0 || parts[0] == "" {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+
+	p.Name = strings.ToUpper(parts[0])
+	p.Value = line[colon+1:]
+
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			return p, fmt.Errorf("invalid parameter %q", param)
+		}
+		p.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return p, nil
+}
+
+// icalTimes parses the values of a DATE or DATE-TIME property. They are read
+// in the property's TZID, in UTC when they end with Z, and as UTC when they
+// are floating. The location is the TZID's one, UTC otherwise.
+func icalTimes(p icalProperty) ([]time.Time, *time.Location, error) {
+	loc := time.UTC
+	if tzid := p.Params["TZID"]; tzid != "" {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+
+	var times []time.Time
+	for _, value := range strings.Split(p.Value, ",") {
+		var t time.Time
+		var err error
+
+		switch {
+		case p.Params["VALUE"] == "DATE" || len(value) == len(icalDateFormat):
+			t, err = time.ParseInLocation(icalDateFormat, value, loc)
+		case strings.HasSuffix(value, "Z"):
+			t, err = time.Parse(icalUTCDateTimeFormat, value)
+		default:
+			t, err = time.ParseInLocation(icalDateTimeFormat, value, loc)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid date %q", value)
+		}
+
+		times = append(times, t)
+	}
+
+	return times, loc, nil
+}
+
+// icalUnescape reverses icalText.
+func icalUnescape(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCalendar(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Paris",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:raid@example.com",
+		"SUMMARY:Raid night\\, bring potions",
+		"DESCRIPTION:A long description folded over",
+		"  two lines",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:other@example.com",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := parseCalendar(strings.NewReader(calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if got := events[0].text("SUMMARY"); got != "Raid night, bring potions" {
+		t.Errorf("got summary %q", got)
+	}
+	if got := events[0].text("DESCRIPTION"); got != "A long description folded over two lines" {
+		t.Errorf("got description %q, want the folded lines joined", got)
+	}
+	if _, ok := events[0].get("TRIGGER"); ok {
+		t.Error("got the property of the VALARM in the VEVENT")
+	}
+	if got := events[1].text("UID"); got != "other@example.com" {
+		t.Errorf("got UID %q", got)
+	}
+}
+
+func TestParseCalendarErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar []string
+		want     string
+	}{
+		{
+			name:     "no event",
+			calendar: []string{"BEGIN:VCALENDAR", "END:VCALENDAR"},
+			want:     "calendar must contain at least one VEVENT",
+		},
+		{
+			name:     "unterminated",
+			calendar: []string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:raid"},
+			want:     "unterminated VEVENT component",
+		},
+		{
+			name:     "mismatched end",
+			calendar: []string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "END:VCALENDAR"},
+			want:     "line 3: unexpected END:VCALENDAR",
+		},
+		{
+			name:     "invalid line",
+			calendar: []string{"BEGIN:VCALENDAR", "not a property"},
+			want:     `line 2: invalid content line "not a property"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCalendar(strings.NewReader(strings.Join(tt.calendar, "\r\n")))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICalProperty(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    icalProperty
+		wantErr bool
+	}{
+		{
+			line: "summary:Raid night",
+			want: icalProperty{Name: "SUMMARY", Params: map[string]string{}, Value: "Raid night"},
+		},
+		{
+			line: "DTSTART;TZID=Europe/Paris:20250602T200000",
+			want: icalProperty{Name: "DTSTART", Params: map[string]string{"TZID": "Europe/Paris"}, Value: "20250602T200000"},
+		},
+		{
+			line: `ORGANIZER;CN="Guild: raids; weekly";role=CHAIR:mailto:raids@example.com`,
+			want: icalProperty{
+				Name:   "ORGANIZER",
+				Params: map[string]string{"CN": "Guild: raids; weekly", "ROLE": "CHAIR"},
+				Value:  "mailto:raids@example.com",
+			},
+		},
+		{line: "SUMMARY", wantErr: true},
+		{line: ":Raid night", wantErr: true},
+		{line: "DTSTART;TZID:20250602T200000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		p, err := parseICalProperty(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseICalProperty(%q) returned error %v", tt.line, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		if p.Name != tt.want.Name || p.Value != tt.want.Value || !maps.Equal(p.Params, tt.want.Params) {
+			t.Errorf("parseICalProperty(%q) = %+v, want %+v", tt.line, p, tt.want)
+		}
+	}
+}
+
+func TestICalTimes(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		line    string
+		want    []time.Time
+		wantLoc *time.Location
+		wantErr bool
+	}{
+		{
+			name:    "TZID",
+			line:    "DTSTART;TZID=Europe/Paris:20250602T200000",
+			want:    []time.Time{time.Date(2025, 6, 2, 20, 0, 0, 0, paris)},
+			wantLoc: paris,
+		},
+		{
+			name:    "UTC",
+			line:    "DTSTART:20250602T200000Z",
+			want:    []time.Time{time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)},
+			wantLoc: time.UTC,
+		},
+		{
+			name:    "floating",
+			line:    "DTSTART:20250602T200000",
+			want:    []time.Time{time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)},
+			wantLoc: time.UTC,
+		},
+		{
+			name:    "date",
+			line:    "DTSTART;VALUE=DATE:20250602",
+			want:    []time.Time{time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+			wantLoc: time.UTC,
+		},
+		{
+			name: "list",
+			line: "EXDATE;TZID=Europe/Paris:20250609T200000,20250616T200000",
+			want: []time.Time{
+				time.Date(2025, 6, 9, 20, 0, 0, 0, paris),
+				time.Date(2025, 6, 16, 20, 0, 0, 0, paris),
+			},
+			wantLoc: paris,
+		},
+		{name: "unknown time zone", line: "DTSTART;TZID=Nowhere/Town:20250602T200000", wantErr: true},
+		{name: "invalid date", line: "DTSTART:2025-06-02", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseICalProperty(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			times, loc, err := icalTimes(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if loc.String() != tt.wantLoc.String() {
+				t.Errorf("got location %s, want %s", loc, tt.wantLoc)
+			}
+			if len(times) != len(tt.want) {
+				t.Fatalf("got times %v, want %v", times, tt.want)
+			}
+			for i := range times {
+				if !times[i].Equal(tt.want[i]) {
+					t.Errorf("got time %s, want %s", times[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestICalUnescape(t *testing.T) {
+	for _, s := range []string{"Raid night", `a\b`, "one; two, three", "line one\nline two"} {
+		if got := icalUnescape(icalText(s)); got != s {
+			t.Errorf("icalUnescape(icalText(%q)) = %q", s, got)
+		}
+	}
+}
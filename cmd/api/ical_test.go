@@ -0,0 +1,237 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+func TestICalTimezone(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		zone icalTimezone
+		want []string
+	}{
+		{
+			name: "daylight saving time",
+			zone: icalTimezone{
+				loc:   paris,
+				start: time.Date(2025, 1, 15, 20, 0, 0, 0, paris),
+				end:   time.Date(2026, 1, 1, 0, 0, 0, 0, paris),
+			},
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:Europe/Paris",
+				"BEGIN:STANDARD",
+				"DTSTART:20241027T030000",
+				"TZOFFSETFROM:+0200",
+				"TZOFFSETTO:+0100",
+				"TZNAME:CET",
+				"END:STANDARD",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:20250330T020000",
+				"TZOFFSETFROM:+0100",
+				"TZOFFSETTO:+0200",
+				"TZNAME:CEST",
+				"END:DAYLIGHT",
+				"BEGIN:STANDARD",
+				"DTSTART:20251026T030000",
+				"TZOFFSETFROM:+0200",
+				"TZOFFSETTO:+0100",
+				"TZNAME:CET",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+		{
+			name: "fixed offset",
+			zone: icalTimezone{
+				loc:   time.FixedZone("Fixed", 5*3600+30*60),
+				start: time.Date(2025, 1, 15, 20, 0, 0, 0, time.UTC),
+				end:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:Fixed",
+				"BEGIN:STANDARD",
+				"DTSTART:19700101T000000",
+				"TZOFFSETFROM:+0530",
+				"TZOFFSETTO:+0530",
+				"TZNAME:Fixed",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			iw := &icalWriter{w: &b}
+			iw.timezone(tt.zone)
+
+			want := strings.Join(tt.want, "\r\n") + "\r\n"
+			if b.String() != want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestICalTimezones(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	first := time.Date(2025, 3, 1, 20, 0, 0, 0, paris)
+	earlier := time.Date(2024, 11, 1, 20, 0, 0, 0, paris)
+	later := time.Date(2040, 1, 1, 20, 0, 0, 0, paris)
+
+	events := []data.Event{
+		{StartDate: first},
+		{StartDate: now},
+		{StartDate: first, RDates: []time.Time{earlier, later}},
+		{StartDate: now},
+	}
+	locs := []*time.Location{paris, time.UTC, paris, tokyo}
+
+	zones := icalTimezones(events, locs, now)
+
+	want := []icalTimezone{
+		{loc: paris, start: earlier, end: later},
+		{loc: tokyo, start: now, end: now.AddDate(icalTimezoneYears, 0, 0)},
+	}
+	if len(zones) != len(want) {
+		t.Fatalf("got %d time zones, want %d", len(zones), len(want))
+	}
+	for i, zone := range zones {
+		if zone.loc != want[i].loc || !zone.start.Equal(want[i].start) || !zone.end.Equal(want[i].end) {
+			t.Errorf("got time zone %s from %s to %s, want %s from %s to %s",
+				zone.loc, zone.start, zone.end, want[i].loc, want[i].start, want[i].end)
+		}
+	}
+}
+
+func TestWriteCalendarEvent(t *testing.T) {
+	id := uuid.MustParse("0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11")
+	start := time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)
+
+	event := data.Event{
+		ID:          id,
+		Title:       "Raid night; bring potions, food",
+		Description: "Line one\nline two",
+		Duration:    "PT2H",
+		RRule:       "rrule:freq=weekly;count=4",
+		StartDate:   start,
+		Timezone:    "UTC",
+		ExDates:     []time.Time{start.AddDate(0, 0, 7)},
+		Overrides: []data.OccurrenceOverride{
+			{EventID: id, OriginalStart: start.AddDate(0, 0, 14), Cancelled: true},
+		},
+		Tags:        []data.Tag{{Name: "raids"}, {Name: "a,b"}},
+		CreatedDate: start,
+		UpdatedDate: start,
+	}
+
+	var b strings.Builder
+	if err := writeCalendar(&b, "Guild", []data.Event{event}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"UID:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11\r\n",
+		"DTSTART:20250602T200000Z\r\n",
+		"DURATION:PT2H\r\n",
+		"RRULE:FREQ=WEEKLY;COUNT=4\r\n",
+		"EXDATE:20250609T200000Z\r\n",
+		"EXDATE:20250616T200000Z\r\n",
+		`SUMMARY:Raid night\; bring potions\, food` + "\r\n",
+		`DESCRIPTION:Line one\nline two` + "\r\n",
+		`CATEGORIES:raids,a\,b` + "\r\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got calendar\n%s\nwant it to contain %q", b.String(), want)
+		}
+	}
+	if strings.Contains(b.String(), "BEGIN:VTIMEZONE") {
+		t.Error("got a VTIMEZONE for an event in UTC")
+	}
+}
+
+func TestICalLine(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"short", "SUMMARY:Raid night"},
+		{"exactly the limit", "SUMMARY:" + strings.Repeat("a", icalMaxLineLength-8)},
+		{"long", "DESCRIPTION:" + strings.Repeat("a", 200)},
+		{"multibyte", "DESCRIPTION:" + strings.Repeat("é", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			iw := &icalWriter{w: &b}
+			iw.line(tt.s)
+
+			out := b.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("got %q, want it terminated by CRLF", out)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > icalMaxLineLength {
+					t.Errorf("got line %d of %d octets, want at most %d", i, len(line), icalMaxLineLength)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("got line %d splitting a UTF-8 sequence", i)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("got continuation line %d without a leading space", i)
+				}
+			}
+
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+			if unfolded != tt.s {
+				t.Errorf("got %q unfolded, want %q", unfolded, tt.s)
+			}
+		})
+	}
+}
+
+func TestICalOffset(t *testing.T) {
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "+0000"},
+		{3600, "+0100"},
+		{-5 * 3600, "-0500"},
+		{5*3600 + 45*60, "+0545"},
+		{-(9*3600 + 30*60), "-0930"},
+		{561, "+000921"},
+	}
+
+	for _, tt := range tests {
+		if got := icalOffset(tt.offset); got != tt.want {
+			t.Errorf("icalOffset(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Discord interaction constants.
+// https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+	interactionMessageComponent   = 3
+
+	responsePong           = 1
+	responseChannelMessage = 4
+
+	messageFlagEphemeral = 1 << 6
+
+	optionSubCommand = 1
+	optionString     = 3
+	optionInteger    = 4
+)
+
+// maxInteractionAge is how old the timestamp of an interaction may be, so a
+// captured request cannot be replayed later.
+const maxInteractionAge = 5 * time.Minute
+
+// discordInteractions receives the interactions Discord posts to the API,
+// signed with the application's public key. Events created from Discord are
+// announced on webhookID unless the command gives another webhook.
+type discordInteractions struct {
+	publicKey ed25519.PublicKey
+	webhookID uuid.UUID
+}
+
+type Interaction struct {
+	ID     string             `json:"id"`
+	Type   int                `json:"type"`
+	Token  string             `json:"token"`
+	Data   InteractionData    `json:"data"`
+	Member *InteractionMember `json:"member,omitempty"`
+	User   *DiscordUser       `json:"user,omitempty"`
+}
+
+// InteractionData is the command run, or the component clicked.
+type InteractionData struct {
+	Name          string              `json:"name,omitempty"`
+	Options       []InteractionOption `json:"options,omitempty"`
+	CustomID      string              `json:"custom_id,omitempty"`
+	ComponentType int                 `json:"component_type,omitempty"`
+}
+
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   json.RawMessage     `json:"value,omitempty"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+type InteractionMember struct {
+	User DiscordUser `json:"user"`
+}
+
+type DiscordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type InteractionResponseData struct {
+	Content         string           `json:"content,omitempty"`
+	Embeds          []Embed          `json:"embeds,omitempty"`
+	Flags           int              `json:"flags,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+type ApplicationCommand struct {
+	Name                     string                     `json:"name"`
+	Description              string                     `json:"description"`
+	Options                  []ApplicationCommandOption `json:"options,omitempty"`
+	DefaultMemberPermissions string                     `json:"default_member_permissions,omitempty"`
+}
+
+type ApplicationCommandOption struct {
+	Type        int                        `json:"type"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Required    bool                       `json:"required,omitempty"`
+	MinValue    *int                       `json:"min_value,omitempty"`
+	MaxValue    *int                       `json:"max_value,omitempty"`
+	Options     []ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// setupDiscordInteractions returns the interactions endpoint when it is
+// enabled in the configuration, nil otherwise.
+func setupDiscordInteractions(cfg config) (*discordInteractions, error) {
+	if !cfg.Discord.Interactions.Enabled {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(cfg.Discord.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("discord public key must be the hex encoded key of the application")
+	}
+
+	interactions := &discordInteractions{publicKey: key}
+
+	if cfg.Discord.Interactions.WebhookID != "" {
+		interactions.webhookID, err = uuid.Parse(cfg.Discord.Interactions.WebhookID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid discord interactions webhook ID: %w", err)
+		}
+	}
+
+	return interactions, nil
+}
+
+// registerCommands registers the slash commands in the guild of the bot, in
+// the background. Without a bot, they have to be registered by hand.
+func (app *application) registerCommands() {
+	if app.interactions == nil || app.bot == nil {
+		return
+	}
+
+	app.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := app.bot.RegisterCommands(ctx, slashCommands())
+		if err != nil {
+			app.logger.Error("Unable to register slash commands", "error", err)
+			return
+		}
+
+		app.logger.Info("Slash commands registered", "guild_id", app.bot.guildID)
+	})
+}
+
+// verifyInteraction reports whether the request was signed by Discord with
+// the key of the application.
+func verifyInteraction(key ed25519.PublicKey, h http.Header, body []byte, now time.Time) bool {
+	signature, err := hex.DecodeString(h.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+
+	timestamp := h.Get("X-Signature-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxInteractionAge || age < -maxInteractionAge {
+		return false
+	}
+
+	message := append([]byte(timestamp), body...)
+
+	return ed25519.Verify(key, message, signature)
+}
+
+// interactionsHandler is the interactions endpoint URL of the Discord
+// application. It answers the pings Discord sends to check it, runs the slash
+// commands, and records the clicks on the RSVP buttons.
+func (app *application) interactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if app.interactions == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !verifyInteraction(app.interactions.publicKey, r.Header, body, time.Now()) {
+		app.errorResponse(w, r, http.StatusUnauthorized, "invalid request signature")
+		return
+	}
+
+	var interaction Interaction
+	err = json.Unmarshal(body, &interaction)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var resp envelope
+
+	switch interaction.Type {
+	case interactionPing:
+		resp = envelope{"type": responsePong}
+	case interactionApplicationCommand:
+		resp = envelope{"type": responseChannelMessage, "data": app.runCommand(interaction)}
+	case interactionMessageComponent:
+		resp = envelope{"type": responseChannelMessage, "data": app.rsvpButtonClicked(interaction)}
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("unsupported interaction type %d", interaction.Type))
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// discordUserID returns the ID of the Discord user who triggered the
+// interaction, in a guild or in a direct message.
+func (i Interaction) discordUserID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+
+	return ""
+}
+
+// commandOptions are the values of the options of a command, by name.
+type commandOptions map[string]json.RawMessage
+
+func newCommandOptions(options []InteractionOption) commandOptions {
+	opts := make(commandOptions, len(options))
+	for _, option := range options {
+		opts[option.Name] = option.Value
+	}
+
+	return opts
+}
+
+func (o commandOptions) string(name, defaultValue string) string {
+	var s string
+	if err := json.Unmarshal(o[name], &s); err != nil || s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+func (o commandOptions) int(name string, defaultValue int) int {
+	var i int
+	if err := json.Unmarshal(o[name], &i); err != nil {
+		return defaultValue
+	}
+
+	return i
+}
+
+// ephemeral returns a reply only the user who ran the command sees.
+func ephemeral(format string, args ...any) InteractionResponseData {
+	return InteractionResponseData{
+		Content: fmt.Sprintf(format, args...),
+		Flags:   messageFlagEphemeral,
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signInteraction returns the headers Discord signs body with at now.
+func signInteraction(key ed25519.PrivateKey, body string, now time.Time) http.Header {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	h := make(http.Header)
+	h.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+body))))
+	h.Set("X-Signature-Timestamp", timestamp)
+
+	return h
+}
+
+func TestVerifyInteraction(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	body := `{"type":1}`
+
+	tests := []struct {
+		name   string
+		header func() http.Header
+		body   string
+		want   bool
+	}{
+		{
+			name:   "signed",
+			header: func() http.Header { return signInteraction(private, body, now) },
+			body:   body,
+			want:   true,
+		},
+		{
+			name:   "signed a while ago",
+			header: func() http.Header { return signInteraction(private, body, now.Add(-maxInteractionAge+time.Minute)) },
+			body:   body,
+			want:   true,
+		},
+		{
+			name:   "other key",
+			header: func() http.Header { return signInteraction(otherPrivate, body, now) },
+			body:   body,
+		},
+		{
+			name:   "tampered body",
+			header: func() http.Header { return signInteraction(private, body, now) },
+			body:   `{"type":2}`,
+		},
+		{
+			name: "tampered timestamp",
+			header: func() http.Header {
+				h := signInteraction(private, body, now)
+				h.Set("X-Signature-Timestamp", strconv.FormatInt(now.Unix()+1, 10))
+				return h
+			},
+			body: body,
+		},
+		{
+			name:   "replayed",
+			header: func() http.Header { return signInteraction(private, body, now.Add(-maxInteractionAge-time.Minute)) },
+			body:   body,
+		},
+		{
+			name:   "from the future",
+			header: func() http.Header { return signInteraction(private, body, now.Add(maxInteractionAge+time.Minute)) },
+			body:   body,
+		},
+		{
+			name: "signature not hex",
+			header: func() http.Header {
+				h := signInteraction(private, body, now)
+				h.Set("X-Signature-Ed25519", "not hex")
+				return h
+			},
+			body: body,
+		},
+		{
+			name: "signature too short",
+			header: func() http.Header {
+				h := signInteraction(private, body, now)
+				h.Set("X-Signature-Ed25519", h.Get("X-Signature-Ed25519")[:64])
+				return h
+			},
+			body: body,
+		},
+		{
+			name: "timestamp not a number",
+			header: func() http.Header {
+				h := signInteraction(private, body, now)
+				h.Set("X-Signature-Timestamp", "yesterday")
+				return h
+			},
+			body: body,
+		},
+		{
+			name:   "unsigned",
+			header: func() http.Header { return make(http.Header) },
+			body:   body,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyInteraction(public, tt.header(), []byte(tt.body), now); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInteractionsHandler(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication()
+	app.interactions = &discordInteractions{publicKey: public}
+
+	tests := []struct {
+		name       string
+		body       string
+		signed     bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"ping", `{"type":1}`, true, http.StatusOK, `{"type":1}`},
+		{"unsigned ping", `{"type":1}`, false, http.StatusUnauthorized, ""},
+		{"unsupported type", `{"type":42}`, true, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/discord/interactions", strings.NewReader(tt.body))
+			if tt.signed {
+				r.Header = signInteraction(private, tt.body, time.Now())
+			}
+			w := httptest.NewRecorder()
+
+			app.interactionsHandler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && strings.Join(strings.Fields(w.Body.String()), "") != tt.wantBody {
+				t.Errorf("got body %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
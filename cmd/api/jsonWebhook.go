@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+type jsonWebhookBody struct {
+	EventID      *uuid.UUID         `json:"event_id,omitempty"`
+	Content      string             `json:"content"`
+	Title        string             `json:"title"`
+	Description  string             `json:"description"`
+	URL          string             `json:"url,omitempty"`
+	Color        string             `json:"color,omitempty"`
+	Fields       []jsonWebhookField `json:"fields,omitempty"`
+	Footer       string             `json:"footer,omitempty"`
+	ThumbnailURL string             `json:"thumbnail_url,omitempty"`
+	Author       *jsonWebhookAuthor `json:"author,omitempty"`
+	Attendance   *data.Attendance   `json:"attendance,omitempty"`
+	StartDate    *time.Time         `json:"start_date,omitempty"`
+	EndDate      *time.Time         `json:"end_date,omitempty"`
+}
+
+type jsonWebhookField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type jsonWebhookAuthor struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// jsonNotifier posts messages as plain JSON, for the services with no
+// dedicated notifier.
+type jsonNotifier struct {
+	client *http.Client
+}
+
+func (n jsonNotifier) Payload(msg Message) any {
+	body := jsonWebhookBody{
+		Content:      msg.Content,
+		Title:        msg.Title,
+		Description:  msg.Description,
+		URL:          msg.URL,
+		Color:        fmt.Sprintf("#%06X", msg.Color),
+		Footer:       msg.Footer,
+		ThumbnailURL: msg.ThumbnailURL,
+		Attendance:   msg.Attendance,
+	}
+
+	for _, field := range msg.Fields {
+		body.Fields = append(body.Fields, jsonWebhookField(field))
+	}
+	if msg.Author.Name != "" {
+		author := jsonWebhookAuthor(msg.Author)
+		body.Author = &author
+	}
+
+	if msg.EventID != uuid.Nil {
+		body.EventID = &msg.EventID
+	}
+	if !msg.StartDate.IsZero() {
+		body.StartDate = &msg.StartDate
+		body.EndDate = &msg.EndDate
+	}
+
+	return body
+}
+
+func (n jsonNotifier) Send(ctx context.Context, url string, payload any) (*notifyResponse, error) {
+	return sendJSON(ctx, n.client, http.MethodPost, url, payload)
+}
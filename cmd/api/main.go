@@ -9,7 +9,6 @@ import (
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/vcs"
 	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 	"log"
@@ -44,17 +43,71 @@ type config struct {
 	Discord struct {
 		ClientID     string `yaml:"client_id"`
 		ClientSecret string `yaml:"client_secret"`
+		BotToken     string `yaml:"bot_token"`
+		GuildID      string `yaml:"guild_id"`
+		APIURL       string `yaml:"api_url"`
+		PublicKey    string `yaml:"public_key"`
+		OAuth        struct {
+			RedirectURL string `yaml:"redirect_url"`
+			FrontendURL string `yaml:"frontend_url"`
+			StateSecret string `yaml:"state_secret"`
+		} `yaml:"oauth"`
+		Interactions struct {
+			Enabled   bool   `yaml:"enabled"`
+			WebhookID string `yaml:"webhook_id"`
+		} `yaml:"interactions"`
+		ScheduledEvents struct {
+			Enabled      bool   `yaml:"enabled"`
+			Horizon      string `yaml:"horizon"`
+			SyncInterval string `yaml:"sync_interval"`
+			Location     string `yaml:"location"`
+		} `yaml:"scheduled_events"`
 	}
+	Scheduler struct {
+		PollInterval   string `yaml:"poll_interval"`
+		Horizon        string `yaml:"horizon"`
+		BatchSize      int    `yaml:"batch_size"`
+		LeaseDuration  string `yaml:"lease_duration"`
+		WorkerID       string `yaml:"worker_id"`
+		MaxAttempts    int    `yaml:"max_attempts"`
+		RetryBaseDelay string `yaml:"retry_base_delay"`
+		RetryMaxDelay  string `yaml:"retry_max_delay"`
+	} `yaml:"scheduler"`
+	Announcements struct {
+		OnCancel string `yaml:"on_cancel"`
+	} `yaml:"announcements"`
+	Occurrences struct {
+		MaxSpan string `yaml:"max_span"`
+	} `yaml:"occurrences"`
+	Calendar struct {
+		Name         string `yaml:"name"`
+		Private      bool   `yaml:"private"`
+		FeedTokenTTL string `yaml:"feed_token_ttl"`
+	} `yaml:"calendar"`
+	CalendarSources struct {
+		PollInterval string `yaml:"poll_interval"`
+		Google       struct {
+			Enabled         bool   `yaml:"enabled"`
+			CredentialsFile string `yaml:"credentials_file"`
+			CalendarID      string `yaml:"calendar_id"`
+			WebhookID       string `yaml:"webhook_id"`
+		} `yaml:"google"`
+	} `yaml:"calendar_sources"`
 }
 
 type application struct {
-	config                 config
-	logger                 *slog.Logger
-	models                 data.Models
-	scheduledEventsTracker map[uuid.UUID]data.Event
-	oauth2Config           oauth2.Config
-	provider               *oidc.Provider
-	wg                     sync.WaitGroup
+	config       config
+	logger       *slog.Logger
+	models       data.Models
+	oauth2Config oauth2.Config
+	provider     *oidc.Provider
+	oauthState   *oauthStateSigner
+	discord      *discordClient
+	bot          *discordBot
+	interactions *discordInteractions
+	notifiers    map[string]Notifier
+	calendars    []calendarSync
+	wg           sync.WaitGroup
 }
 
 func main() {
@@ -76,6 +129,37 @@ func main() {
 	viper.SetDefault("Cors.TrustedOrigins", []string{"http://localhost:3000"})
 	viper.SetDefault("Discord.ClientID", "")
 	viper.SetDefault("Discord.ClientSecret", "")
+	viper.SetDefault("Discord.BotToken", "")
+	viper.SetDefault("Discord.GuildID", "")
+	viper.SetDefault("Discord.APIURL", "https://discord.com/api/v10")
+	viper.SetDefault("Discord.PublicKey", "")
+	viper.SetDefault("Discord.OAuth.RedirectURL", "")
+	viper.SetDefault("Discord.OAuth.FrontendURL", "")
+	viper.SetDefault("Discord.OAuth.StateSecret", "")
+	viper.SetDefault("Discord.Interactions.Enabled", false)
+	viper.SetDefault("Discord.Interactions.WebhookID", "")
+	viper.SetDefault("Discord.ScheduledEvents.Enabled", false)
+	viper.SetDefault("Discord.ScheduledEvents.Horizon", "168h")
+	viper.SetDefault("Discord.ScheduledEvents.SyncInterval", "15m")
+	viper.SetDefault("Discord.ScheduledEvents.Location", "Discord")
+	viper.SetDefault("Scheduler.PollInterval", "30s")
+	viper.SetDefault("Scheduler.Horizon", "24h")
+	viper.SetDefault("Scheduler.BatchSize", 20)
+	viper.SetDefault("Scheduler.LeaseDuration", "5m")
+	viper.SetDefault("Scheduler.WorkerID", defaultWorkerID())
+	viper.SetDefault("Scheduler.MaxAttempts", 5)
+	viper.SetDefault("Scheduler.RetryBaseDelay", "30s")
+	viper.SetDefault("Scheduler.RetryMaxDelay", "30m")
+	viper.SetDefault("Announcements.OnCancel", "delete")
+	viper.SetDefault("Occurrences.MaxSpan", "2232h")
+	viper.SetDefault("Calendar.Name", "GoEventBot")
+	viper.SetDefault("Calendar.Private", false)
+	viper.SetDefault("Calendar.FeedTokenTTL", "8760h")
+	viper.SetDefault("CalendarSources.PollInterval", "1m")
+	viper.SetDefault("CalendarSources.Google.Enabled", false)
+	viper.SetDefault("CalendarSources.Google.CredentialsFile", "/config/service_account.json")
+	viper.SetDefault("CalendarSources.Google.CalendarID", "")
+	viper.SetDefault("CalendarSources.Google.WebhookID", "")
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Panic("Error reading config file: ", err)
@@ -98,7 +182,8 @@ func main() {
 
 	db, err := openDB(cfg)
 	if err != nil {
-		logger.Error("Error while opening database connection: ", err)
+		logger.Error("Error while opening database connection", "error", err)
+		os.Exit(1)
 	}
 	defer db.Close()
 
@@ -106,7 +191,31 @@ func main() {
 
 	oauth2Config, provider, err := setupOauth(cfg)
 	if err != nil {
-		logger.Error("Error setting up OAuth2 configuration: ", err)
+		logger.Error("Error setting up OAuth2 configuration", "error", err)
+		os.Exit(1)
+	}
+
+	oauthState, err := newOAuthStateSigner(cfg.Discord.OAuth.StateSecret)
+	if err != nil {
+		logger.Error("Error setting up OAuth2 state", "error", err)
+		os.Exit(1)
+	}
+
+	calendars, err := setupCalendarSources(context.Background(), cfg)
+	if err != nil {
+		logger.Error("Error setting up calendar sources", "error", err)
+		os.Exit(1)
+	}
+
+	bot, err := setupDiscordBot(cfg)
+	if err != nil {
+		logger.Error("Error setting up the Discord bot", "error", err)
+		os.Exit(1)
+	}
+
+	interactions, err := setupDiscordInteractions(cfg)
+	if err != nil {
+		logger.Error("Error setting up Discord interactions", "error", err)
 		os.Exit(1)
 	}
 
@@ -124,20 +233,39 @@ func main() {
 		return time.Now().Unix()
 	}))
 
+	discord := newDiscordClient()
+
 	app := &application{
 		config:       cfg,
 		logger:       logger,
 		models:       data.NewModels(db),
 		oauth2Config: oauth2Config,
 		provider:     provider,
+		oauthState:   oauthState,
+		discord:      discord,
+		bot:          bot,
+		interactions: interactions,
+		notifiers:    newNotifiers(discord, interactions != nil),
+		calendars:    calendars,
 	}
 
 	err = app.serve()
 	if err != nil {
-		logger.Error("Error when running the app: ", "error", err)
+		logger.Error("Error when running the app", "error", err)
 	}
 }
 
+// defaultWorkerID identifies this process on the jobs it claims, it stays
+// unique when several replicas run on the same host.
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.DB.DSN)
 	if err != nil {
@@ -169,7 +297,10 @@ func setupOauth(cfg config) (oauth2.Config, *oidc.Provider, error) {
 	ctx := context.Background()
 	var oauth2Config oauth2.Config
 	var provider *oidc.Provider
-	redirectUrl := fmt.Sprintf("http://localhost:%d/oauth/callback", cfg.Port)
+	redirectUrl := cfg.Discord.OAuth.RedirectURL
+	if redirectUrl == "" {
+		redirectUrl = fmt.Sprintf("http://localhost:%d/oauth/callback", cfg.Port)
+	}
 	provider, err := oidc.NewProvider(ctx, "https://discord.com")
 	if err != nil {
 		return oauth2Config, provider, err
@@ -180,7 +311,7 @@ func setupOauth(cfg config) (oauth2.Config, *oidc.Provider, error) {
 		ClientSecret: cfg.Discord.ClientSecret,
 		Endpoint:     endpoints,
 		RedirectURL:  redirectUrl,
-		Scopes:       []string{"identify", "guilds.join"},
+		Scopes:       []string{oidc.ScopeOpenID, "identify", "email"},
 	}
 
 	return oauth2Config, provider, nil
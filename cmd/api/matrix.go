@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type matrixBody struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// matrixNotifier sends messages to a Matrix room through the client-server
+// API. The webhook URL is the room's send endpoint for m.room.message with
+// the access token of the account posting in the room.
+type matrixNotifier struct {
+	client *http.Client
+}
+
+func (n matrixNotifier) Payload(msg Message) any {
+	var plain, formatted strings.Builder
+
+	if msg.Author.Name != "" {
+		plain.WriteString(msg.Author.Name + "\n")
+		formatted.WriteString("<p><sub>" + html.EscapeString(msg.Author.Name) + "</sub></p>")
+	}
+
+	plain.WriteString(msg.Title)
+	title := html.EscapeString(msg.Title)
+	if msg.URL != "" {
+		plain.WriteString("\n" + msg.URL)
+		title = `<a href="` + html.EscapeString(msg.URL) + `">` + title + "</a>"
+	}
+	formatted.WriteString("<h4>" + title + "</h4>")
+
+	if msg.Description != "" {
+		plain.WriteString("\n\n" + msg.Description)
+		formatted.WriteString("<p>" + matrixHTML(msg.Description) + "</p>")
+	}
+
+	if !msg.StartDate.IsZero() {
+		date := announcementTime(msg.StartDate)
+		plain.WriteString("\n\n" + date)
+		formatted.WriteString("<p><em>" + html.EscapeString(date) + "</em></p>")
+	}
+
+	if len(msg.Fields) > 0 {
+		plain.WriteString("\n")
+		formatted.WriteString("<ul>")
+		for _, field := range msg.Fields {
+			plain.WriteString("\n" + field.Name + ": " + field.Value)
+			formatted.WriteString("<li><strong>" + html.EscapeString(field.Name) + "</strong>: " + matrixHTML(field.Value) + "</li>")
+		}
+		formatted.WriteString("</ul>")
+	}
+
+	if msg.Footer != "" {
+		plain.WriteString("\n\n" + msg.Footer)
+		formatted.WriteString("<p><sub>" + matrixHTML(msg.Footer) + "</sub></p>")
+	}
+
+	return matrixBody{
+		MsgType:       "m.text",
+		Body:          plain.String(),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted.String(),
+	}
+}
+
+// Send puts the message under a new transaction ID, which Matrix expects
+// at the end of the send endpoint.
+func (n matrixNotifier) Send(ctx context.Context, rawURL string, payload any) (*notifyResponse, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+
+	txnID := uuid.NewString()
+	u.Path += "/" + txnID
+	if u.RawPath != "" {
+		u.RawPath += "/" + txnID
+	}
+
+	return sendJSON(ctx, n.client, http.MethodPut, u.String(), payload)
+}
+
+func matrixHTML(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
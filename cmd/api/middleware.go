@@ -15,6 +15,7 @@ import (
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
 
 	"github.com/felixge/httpsnoop"
+	"github.com/julienschmidt/httprouter"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 )
@@ -179,6 +180,21 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	return app.requireActivatedUser(fn)
 }
 
+// routeStatic serves the requests whose :id parameter is segment with
+// static, and the others with next. httprouter cannot register a static
+// segment next to a parameter, such as /v1/events/occurrences along with
+// /v1/events/:id.
+func (app *application) routeStatic(segment string, static, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if httprouter.ParamsFromContext(r.Context()).ByName("id") == segment {
+			static.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
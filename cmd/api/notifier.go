@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+// Message is an announcement, independent of the channel it is posted to.
+// Content is the line shown above it, and the dates are left zero when the
+// event has no upcoming occurrence. Mentions are the only ones the message
+// may ping. Attendance is set for the occurrences of the events taking RSVPs,
+// OccurrenceDate being the start the rule gives the occurrence. The channels
+// which have no equivalent for a part of the message leave it out.
+type Message struct {
+	EventID        uuid.UUID
+	Content        string
+	Title          string
+	Description    string
+	URL            string
+	Color          int
+	Fields         []MessageField
+	Footer         string
+	ThumbnailURL   string
+	Author         MessageAuthor
+	Mentions       data.Mentions
+	Attendance     *data.Attendance
+	OccurrenceDate time.Time
+	StartDate      time.Time
+	EndDate        time.Time
+}
+
+type MessageField struct {
+	Name   string
+	Value  string
+	Inline bool
+}
+
+type MessageAuthor struct {
+	Name    string
+	URL     string
+	IconURL string
+}
+
+// Notifier posts messages to one type of channel. Payload renders the body
+// sent for msg, so it can be shown without being sent.
+type Notifier interface {
+	Payload(msg Message) any
+	Send(ctx context.Context, url string, payload any) (*notifyResponse, error)
+}
+
+// MessageEditor is implemented by the notifiers of the channels whose
+// messages can be edited or deleted once sent, given the ID Send returned.
+type MessageEditor interface {
+	Edit(ctx context.Context, url, messageID string, payload any) error
+	Delete(ctx context.Context, url, messageID string) error
+}
+
+// notifyResponse is what the channel answered to a message. MessageID is
+// only set by the channels implementing MessageEditor.
+type notifyResponse struct {
+	StatusCode int
+	Body       []byte
+	MessageID  string
+}
+
+// newNotifiers returns the notifier of every channel type. Discord webhooks
+// go through the rate limited client, the other channels through one which
+// only reaches public addresses, as their URLs may point anywhere.
+func newNotifiers(discord *discordClient, rsvpButtons bool) map[string]Notifier {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: publicTransport()}
+
+	return map[string]Notifier{
+		data.ChannelDiscord: discordNotifier{client: discord, rsvpButtons: rsvpButtons},
+		data.ChannelSlack:   slackNotifier{client: client},
+		data.ChannelTeams:   teamsNotifier{client: client},
+		data.ChannelMatrix:  matrixNotifier{client: client},
+		data.ChannelJSON:    jsonNotifier{client: client},
+	}
+}
+
+var errNonPublicAddress = errors.New("the webhook resolves to a non-public address")
+
+// publicTransport dials public addresses only, the loopback, link-local and
+// private ones being refused once the host is resolved, so that webhooks
+// cannot reach the services of the server's network. No proxy is used, for
+// the check to apply to the webhook's host.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return errNonPublicAddress
+			}
+			return nil
+		},
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+
+	return t
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// notifier returns the notifier of the webhook's channel type. Discord rejects
+// the buttons sent through the webhooks the application does not own, so
+// the RSVP buttons are left out of theirs.
+func (app *application) notifier(webhook *data.Webhook) (Notifier, error) {
+	n, ok := app.notifiers[webhook.ChannelType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported channel type %q", webhook.ChannelType)
+	}
+
+	if d, ok := n.(discordNotifier); ok && !webhook.ApplicationOwned {
+		d.rsvpButtons = false
+		n = d
+	}
+
+	return n, nil
+}
+
+// notify is the single path every message takes to reach a webhook.
+func (app *application) notify(ctx context.Context, webhook *data.Webhook, msg Message) (*notifyResponse, error) {
+	n, err := app.notifier(webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Send(ctx, webhook.URL, n.Payload(msg))
+}
+
+// sendJSON sends payload as JSON to url. A non-2xx response is returned along
+// with an error. The errors leave out url, which holds the webhook's secret.
+func sendJSON(ctx context.Context, client *http.Client, method, url string, payload any) (*notifyResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, 1_048_576))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &notifyResponse{
+		StatusCode: res.StatusCode,
+		Body:       resBody,
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, fmt.Errorf("channel responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(resp.Body))
+	}
+
+	return resp, nil
+}
+
+// announcementTime formats the start of an occurrence for the channels which
+// cannot render a timestamp in the reader's time zone.
+func announcementTime(t time.Time) string {
+	return t.Format("Monday 2 January 2006 15:04 MST")
+}
@@ -1,28 +1,158 @@
 package main
 
 import (
-	"golang.org/x/oauth2"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"golang.org/x/oauth2"
 )
 
-func (app *application) authenticateHandler(w http.ResponseWriter, r *http.Request) {
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// oauthStateSigner signs the state of the OAuth2 flow, kept in a cookie
+// between the redirection to Discord and the callback. Without a configured
+// secret, a random one is used, and the flows in progress fail on restart.
+type oauthStateSigner struct {
+	key []byte
+}
+
+func newOAuthStateSigner(secret string) (*oauthStateSigner, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &oauthStateSigner{key: key}, nil
+}
+
+// Sign returns the cookie value holding the state until expiry.
+func (s *oauthStateSigner) Sign(state string, expiry time.Time) string {
+	payload := state + "|" + strconv.FormatInt(expiry.Unix(), 10)
+
+	return payload + "|" + s.mac(payload)
+}
+
+// Verify returns the state of the cookie value, if it was signed by s and
+// has not expired.
+func (s *oauthStateSigner) Verify(value string, now time.Time) (string, bool) {
+	i := strings.LastIndex(value, "|")
+	if i < 0 {
+		return "", false
+	}
+	payload, mac := value[:i], value[i+1:]
+
+	if !hmac.Equal([]byte(mac), []byte(s.mac(payload))) {
+		return "", false
+	}
+
+	state, expiry, ok := strings.Cut(payload, "|")
+	if !ok {
+		return "", false
+	}
 
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || !now.Before(time.Unix(seconds, 0)) {
+		return "", false
+	}
+
+	return state, true
+}
+
+func (s *oauthStateSigner) mac(payload string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// discordClaims are the claims of the Discord user info beyond the standard
+// ones.
+type discordClaims struct {
+	Nickname          string `json:"nickname"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+func (app *application) authenticateHandler(w http.ResponseWriter, r *http.Request) {
 	state, err := app.models.OAuth.GenerateState()
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+
+	expiry := time.Now().Add(oauthStateTTL)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    app.oauthState.Sign(state, expiry),
+		Path:     "/oauth",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(app.oauth2Config.RedirectURL, "https://"),
+		// Lax, for the cookie to come along with the redirection back from
+		// Discord.
+		SameSite: http.SameSiteLaxMode,
+	})
+
 	url := app.oauth2Config.AuthCodeURL(state)
 
 	http.Redirect(w, r, url, http.StatusFound)
 }
 
+// callbackHandler is where Discord redirects the user once they authorized
+// the application. The Discord user is signed in as the user linked to them,
+// linked to the activated user with the same verified email address, or
+// registered as a new user. The authentication token issued is handed over to the frontend in
+// the fragment of its URL, or returned as JSON when no frontend is configured.
 func (app *application) callbackHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	qs := r.URL.Query()
 
-	token, err := app.oauth2Config.Exchange(ctx, r.URL.Query().Get("code"))
+	// The state cookie only serves once.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     "/oauth",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	if code := qs.Get("error"); code != "" {
+		app.oauthErrorResponse(w, r, code, "the authorization was denied")
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.oauthErrorResponse(w, r, "invalid_state", "missing or expired state")
+		return
+	}
+
+	state, ok := app.oauthState.Verify(cookie.Value, time.Now())
+	if !ok || subtle.ConstantTimeCompare([]byte(state), []byte(qs.Get("state"))) != 1 {
+		app.oauthErrorResponse(w, r, "invalid_state", "missing or expired state")
+		return
+	}
+
+	token, err := app.oauth2Config.Exchange(ctx, qs.Get("code"))
+	if err != nil {
+		app.oauthErrorResponse(w, r, "invalid_grant", "invalid authorization code")
 		return
 	}
 	userInfo, err := app.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
@@ -30,6 +160,160 @@ func (app *application) callbackHandler(w http.ResponseWriter, r *http.Request)
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	app.logger.Info("User Info", "user", userInfo)
 
+	var claims discordClaims
+	err = userInfo.Claims(&claims)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.models.Users.GetByDiscordID(userInfo.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, data.ErrRecordNotFound):
+		if userInfo.Email == "" || !userInfo.EmailVerified {
+			app.oauthErrorResponse(w, r, "email_required", "the Discord account must have a verified email address")
+			return
+		}
+
+		name := claims.Nickname
+		if name == "" {
+			name = claims.PreferredUsername
+		}
+
+		user, err = app.linkDiscordUser(userInfo.Subject, userInfo.Email, name)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrDuplicateDiscordID):
+				app.oauthErrorResponse(w, r, "account_conflict", "a user with this email address is linked to another Discord account")
+			case errors.Is(err, errAccountNotActivated):
+				app.oauthErrorResponse(w, r, "account_not_activated", "the user with this email address must be activated, or linked to the Discord account by an administrator")
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	default:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	authToken, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Info("User signed in with Discord", "user_id", user.ID, "discord_id", user.DiscordID)
+
+	if app.config.Discord.OAuth.FrontendURL == "" {
+		err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": authToken}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.redirectToFrontend(w, r, url.Values{
+		"token":  {authToken.Plaintext},
+		"expiry": {authToken.Expiry.Format(time.RFC3339)},
+	})
+}
+
+// errAccountNotActivated is returned when the user with the email address of
+// a Discord user is not activated. Anyone may have registered it, with a
+// password they know, so the Discord user is not signed in as them.
+var errAccountNotActivated = errors.New("account not activated")
+
+// linkDiscordUser links the Discord user to the activated user with the
+// verified email address, registering them when there is none. Discord
+// verified the email address, so a registered user is activated. The users
+// not activated yet are linked by an administrator, with PUT
+// /v1/users/discord.
+func (app *application) linkDiscordUser(discordID, email, name string) (*data.User, error) {
+	user, err := app.models.Users.GetByEmail(email)
+	switch {
+	case err == nil:
+		if user.DiscordID != "" && user.DiscordID != discordID {
+			return nil, data.ErrDuplicateDiscordID
+		}
+		if !user.Activated {
+			return nil, errAccountNotActivated
+		}
+
+		user.DiscordID = discordID
+
+		err = app.models.Users.Update(user)
+		if err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return nil, err
+	}
+
+	isFirstUser := app.models.Users.ValidateIsFirstUser()
+
+	user = &data.User{
+		Name:      name,
+		Email:     email,
+		Activated: true,
+		DiscordID: discordID,
+	}
+
+	// The user signs in with Discord, until they reset their password.
+	password, err := app.models.OAuth.GenerateState()
+	if err != nil {
+		return nil, err
+	}
+	err = user.Password.Set(password)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		return nil, fmt.Errorf("invalid Discord user: %v", v.Errors)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.grantInitialPermissions(user, isFirstUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// oauthErrorResponse sends the user back to the frontend with the error code,
+// or answers with the error message when no frontend is configured.
+func (app *application) oauthErrorResponse(w http.ResponseWriter, r *http.Request, code, message string) {
+	if app.config.Discord.OAuth.FrontendURL == "" {
+		app.errorResponse(w, r, http.StatusBadRequest, message)
+		return
+	}
+
+	app.redirectToFrontend(w, r, url.Values{"error": {code}})
+}
+
+// redirectToFrontend redirects to the frontend with values in the fragment,
+// which browsers do not send to servers.
+func (app *application) redirectToFrontend(w http.ResponseWriter, r *http.Request, values url.Values) {
+	u, err := url.Parse(app.config.Discord.OAuth.FrontendURL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	u.Fragment = ""
+
+	http.Redirect(w, r, u.String()+"#"+values.Encode(), http.StatusFound)
 }
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	duration "github.com/channelmeter/iso8601duration"
+	"github.com/teambition/rrule-go"
+)
+
+// listOccurrencesHandler expands the active events into their occurrences
+// between ?from= and ?to=, rendered in the ?tz= time zone. Both bounds accept
+// an RFC 3339 timestamp or a plain date, which is read as midnight in tz.
+// Without bounds the current month is returned.
+func (app *application) listOccurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	loc, err := time.LoadLocation(app.readString(qs, "tz", "UTC"))
+	if err != nil {
+		v.AddError("tz", "must be a valid IANA time zone name")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	now := time.Now().In(loc)
+	firstDayMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+
+	from := app.readTime(qs, "from", firstDayMonth, loc, v)
+	to := app.readTime(qs, "to", firstDayMonth.AddDate(0, 1, 0), loc, v)
+
+	maxSpan, err := time.ParseDuration(app.config.Occurrences.MaxSpan)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v.Check(to.After(from), "to", "must be after from")
+	v.Check(to.Sub(from) <= maxSpan, "to", fmt.Sprintf("must not be more than %s after from", maxSpan))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, err := app.models.Events.GetActiveEvents()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	occurrences, err := expandOccurrences(events, from, to, loc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"occurrences": occurrences,
+		"from":        from,
+		"to":          to,
+		"tz":          loc.String(),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// expandOccurrences returns the instances of events starting in [from, to),
+// sorted by start date, with their dates in loc.
+func expandOccurrences(events []data.Event, from, to time.Time, loc *time.Location) ([]data.EventInstance, error) {
+	instances := []data.EventInstance{}
+
+	for _, event := range events {
+		eventInstances, err := expandEvent(event, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %w", event.ID, err)
+		}
+
+		for _, instance := range eventInstances {
+			instance.OriginalStart = instance.OriginalStart.In(loc)
+			instance.StartDate = instance.StartDate.In(loc)
+			instance.EndDate = instance.EndDate.In(loc)
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].StartDate.Before(instances[j].StartDate)
+	})
+
+	return instances, nil
+}
+
+// expandEvent returns the instances of the event starting in [from, to) once
+// its overrides are applied. An occurrence moved into the interval is part of
+// it even when the rule places it outside, and conversely.
+func expandEvent(event data.Event, from, to time.Time) ([]data.EventInstance, error) {
+	set, err := eventRule(event)
+	if err != nil {
+		return nil, err
+	}
+
+	inInterval := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+
+	instances := []data.EventInstance{}
+
+	for _, start := range set.Between(from, to, true) {
+		if !inInterval(start) {
+			continue
+		}
+
+		instance, err := newEventInstance(event, start)
+		if err != nil {
+			return nil, err
+		}
+
+		if inInterval(instance.StartDate) {
+			instances = append(instances, instance)
+		}
+	}
+
+	for _, override := range event.Overrides {
+		if override.Cancelled || override.StartDate == nil {
+			continue
+		}
+		if inInterval(override.OriginalStart) || !inInterval(*override.StartDate) {
+			continue
+		}
+		if !occursAt(set, override.OriginalStart) {
+			continue
+		}
+
+		instance, err := newEventInstance(event, override.OriginalStart)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// nextInstance returns the first instance of the event starting after the
+// given time, or nil when the event does not occur anymore.
+func nextInstance(event data.Event, after time.Time) (*data.EventInstance, error) {
+	set, err := eventRule(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var next *data.EventInstance
+
+	for start := set.After(after, false); !start.IsZero(); start = set.After(start, false) {
+		instance, err := newEventInstance(event, start)
+		if err != nil {
+			return nil, err
+		}
+
+		if instance.StartDate.After(after) {
+			next = &instance
+			break
+		}
+	}
+
+	// An occurrence moved earlier may come before the next one of the rule.
+	for _, override := range event.Overrides {
+		if override.Cancelled || override.StartDate == nil || !override.StartDate.After(after) {
+			continue
+		}
+		if next != nil && !override.StartDate.Before(next.StartDate) {
+			continue
+		}
+		if !occursAt(set, override.OriginalStart) {
+			continue
+		}
+
+		instance, err := newEventInstance(event, override.OriginalStart)
+		if err != nil {
+			return nil, err
+		}
+		next = &instance
+	}
+
+	return next, nil
+}
+
+// newEventInstance returns the occurrence of the event the rule starts at
+// start, with its override applied.
+func newEventInstance(event data.Event, start time.Time) (data.EventInstance, error) {
+	instance := data.EventInstance{
+		EventID:       event.ID,
+		Title:         event.Title,
+		Description:   event.Description,
+		Duration:      event.Duration,
+		Tags:          event.Tags,
+		OriginalStart: start,
+		StartDate:     start,
+	}
+
+	for _, override := range event.Overrides {
+		if !override.OriginalStart.Equal(start) {
+			continue
+		}
+
+		if override.Title != nil {
+			instance.Title = *override.Title
+		}
+		if override.Description != nil {
+			instance.Description = *override.Description
+		}
+		if override.StartDate != nil {
+			instance.StartDate = override.StartDate.In(start.Location())
+		}
+		if override.Duration != nil {
+			instance.Duration = *override.Duration
+		}
+		break
+	}
+
+	perEventDuration, err := duration.FromString(instance.Duration)
+	if err != nil {
+		return data.EventInstance{}, err
+	}
+
+	instance.EndDate = instance.StartDate.Add(perEventDuration.ToDuration())
+
+	return instance, nil
+}
+
+// occursAt reports whether the set has an occurrence starting at t.
+func occursAt(set *rrule.Set, t time.Time) bool {
+	return len(set.Between(t, t, true)) > 0
+}
+
+// patchOccurrenceHandler cancels or modifies the single occurrence of the
+// event the rule starts at :start, an RFC 3339 timestamp. Fields left out of
+// the request keep their current override, or the event's value.
+func (app *application) patchOccurrenceHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	start, err := app.readStartParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Cancelled   *bool      `json:"cancelled"`
+		Title       *string    `json:"title"`
+		Description *string    `json:"description"`
+		StartDate   *time.Time `json:"start_date"`
+		Duration    *string    `json:"duration"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	override, err := app.models.Occurrences.GetOverride(eventID, start)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// Without an override yet, start must be an occurrence of the event.
+		set, err := eventRule(event)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !occursAt(set, start) {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		override = &data.OccurrenceOverride{EventID: eventID, OriginalStart: start}
+	}
+
+	if input.Cancelled != nil {
+		override.Cancelled = *input.Cancelled
+	}
+	if input.Title != nil {
+		override.Title = input.Title
+	}
+	if input.Description != nil {
+		override.Description = input.Description
+	}
+	if input.StartDate != nil {
+		override.StartDate = input.StartDate
+	}
+	if input.Duration != nil {
+		override.Duration = input.Duration
+	}
+
+	v := validator.New()
+	if data.ValidateOccurrenceOverride(v, override); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	announced, err := app.announcedJobs(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Occurrences.UpsertOverride(override)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The scheduler materializes the occurrence again with its new date.
+	err = app.models.Jobs.DeletePendingForEvent(eventID)
+	if err != nil {
+		app.logger.Error("Unable to reset pending jobs", "event_id", eventID, "error", err)
+	}
+
+	event, err = app.models.Events.Get(eventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.reviseAnnouncements(event, announced, false)
+	app.refreshScheduledEvents(event)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"override": override}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+)
+
+func (app *application) listEventRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reminders := event.Reminders
+	if reminders == nil {
+		reminders = []data.Reminder{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reminders": reminders}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createReminderHandler adds a reminder to the event of the request's :id.
+func (app *application) createReminderHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Offset     string                `json:"offset"`
+		RelativeTo string                `json:"relative_to"`
+		Template   *data.MessageTemplate `json:"template"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reminder := &data.Reminder{
+		EventID:    eventID,
+		Offset:     input.Offset,
+		RelativeTo: input.RelativeTo,
+		Template:   input.Template,
+	}
+	if reminder.RelativeTo == "" {
+		reminder.RelativeTo = data.ReminderStart
+	}
+	if reminder.Template != nil && reminder.Template.IsZero() {
+		reminder.Template = nil
+	}
+
+	v := validator.New()
+	data.ValidateReminder(v, reminder)
+	validateMessageTemplate(v, reminder.Template)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Events.Get(reminder.EventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Reminders.Insert(reminder)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The event's default announcement is replaced by its reminders.
+	err = app.models.Jobs.DeletePendingForEvent(reminder.EventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"reminder": reminder}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getReminderHandler(w http.ResponseWriter, r *http.Request) {
+	reminderID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reminder, err := app.models.Reminders.Get(reminderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reminder": reminder}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateReminderHandler(w http.ResponseWriter, r *http.Request) {
+	reminderID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Offset     *string               `json:"offset"`
+		RelativeTo *string               `json:"relative_to"`
+		Template   *data.MessageTemplate `json:"template"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reminder, err := app.models.Reminders.Get(reminderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if input.Offset != nil {
+		reminder.Offset = *input.Offset
+	}
+	if input.RelativeTo != nil {
+		reminder.RelativeTo = *input.RelativeTo
+	}
+	if input.Template != nil {
+		// An empty template resets the reminder to the event's one.
+		reminder.Template = input.Template
+		if input.Template.IsZero() {
+			reminder.Template = nil
+		}
+	}
+
+	v := validator.New()
+	data.ValidateReminder(v, reminder)
+	validateMessageTemplate(v, reminder.Template)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reminders.Update(reminder)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Jobs.DeletePendingForEvent(reminder.EventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reminder": reminder}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	reminderID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reminder, err := app.models.Reminders.Get(reminderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Reminders.Delete(reminder.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Without its last reminder, the event falls back to being announced at
+	// the start of its occurrences.
+	err = app.models.Jobs.DeletePendingForEvent(reminder.EventID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "Reminder deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -18,18 +18,38 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.requireAuthenticatedUser(app.updateUserPasswordHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/discord", app.requirePermission("admin:write", app.linkDiscordUserHandler))
 
 	router.HandlerFunc(http.MethodGet, "/oauth/authenticate", app.authenticateHandler)
-	router.HandlerFunc(http.MethodPost, "/oauth/callback", app.callbackHandler)
+	router.HandlerFunc(http.MethodGet, "/oauth/callback", app.callbackHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/calendar-feed", app.requireActivatedUser(app.createCalendarFeedTokenHandler))
 
 	// Events routes
 	router.HandlerFunc(http.MethodPost, "/v1/events", app.requireAuthenticatedUser(app.createEventHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/events/:id", app.getEventHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/events/:id", app.routeStatic("import", app.requireAuthenticatedUser(app.importEventsHandler), app.methodNotAllowedResponse))
+	router.HandlerFunc(http.MethodGet, "/v1/events/:id", app.routeStatic("occurrences", app.requireAuthenticatedUser(app.listOccurrencesHandler), app.getEventHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/events", app.requireAuthenticatedUser(app.getAllEventsHandler))
 	router.HandlerFunc(http.MethodPut, "/v1/events/:id", app.requireAuthenticatedUser(app.updateEventHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/events/:id", app.requireAuthenticatedUser(app.deleteEventHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/events/:id/tags/:tag_id", app.requireAuthenticatedUser(app.addEventTagHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/events/:id/tags/:tag_id", app.requireAuthenticatedUser(app.removeEventTagHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/events/:id/webhooks/:webhook_id", app.requireAuthenticatedUser(app.addEventWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/events/:id/webhooks/:webhook_id", app.requireAuthenticatedUser(app.removeEventWebhookHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/events/:id/occurrences/:start", app.requireAuthenticatedUser(app.patchOccurrenceHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/events/:id/occurrences/:start/rsvps", app.requireAuthenticatedUser(app.listRSVPsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/events/:id/occurrences/:start/rsvp", app.requireAuthenticatedUser(app.rsvpHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/events/:id/occurrences/:start/rsvp", app.requireAuthenticatedUser(app.deleteRSVPHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/events/:id/reminders", app.requireAuthenticatedUser(app.listEventRemindersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/events/:id/reminders", app.requireAuthenticatedUser(app.createReminderHandler))
+
+	// Reminders routes
+	router.HandlerFunc(http.MethodGet, "/v1/reminders/:id", app.requireAuthenticatedUser(app.getReminderHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/reminders/:id", app.requireAuthenticatedUser(app.updateReminderHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/reminders/:id", app.requireAuthenticatedUser(app.deleteReminderHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/calendar.ics", app.calendarFeedHandler)
 
 	// Tags routes
 	router.HandlerFunc(http.MethodPost, "/v1/tags", app.requireAuthenticatedUser(app.createTagHandler))
@@ -37,6 +57,24 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/tags", app.listTagsHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/tags/:id", app.requireAuthenticatedUser(app.updateTagHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/tags/:id", app.requireAuthenticatedUser(app.deleteTagHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tags/:id/calendar.ics", app.tagCalendarFeedHandler)
+
+	router.HandlerFunc(http.MethodPost, "/v1/templates/preview", app.requireAuthenticatedUser(app.previewTemplateHandler))
+
+	// Webhooks routes
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks", app.requirePermission("admin:write", app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id", app.requireAuthenticatedUser(app.getWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks", app.requireAuthenticatedUser(app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/webhooks/:id", app.requirePermission("admin:write", app.updateWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", app.requireAuthenticatedUser(app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/test", app.requirePermission("admin:write", app.testWebhookHandler))
+
+	// Discord routes
+	router.HandlerFunc(http.MethodPost, "/v1/discord/interactions", app.interactionsHandler)
+
+	// Dead-lettered jobs routes
+	router.HandlerFunc(http.MethodGet, "/v1/dead-lettered-jobs", app.requirePermission("admin:read", app.listDeadLetteredJobsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/dead-lettered-jobs/:id/replay", app.requirePermission("admin:write", app.replayJobHandler))
 
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
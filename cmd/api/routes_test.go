@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRoutesStaticSegments checks that the static segments sharing their
+// position with :id reach their handlers, which require authentication, and
+// that the IDs reach the event's.
+func TestRoutesStaticSegments(t *testing.T) {
+	const eventPath = "/v1/events/5f0e1c7e-8a47-4a5c-9d47-2f1f0c3e8d11"
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/v1/events/import", http.StatusUnauthorized},
+		{http.MethodPost, eventPath, http.StatusMethodNotAllowed},
+		{http.MethodPost, eventPath + "/reminders", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/events/occurrences", http.StatusUnauthorized},
+	}
+
+	routes := newTestApplication().routes()
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rr.Code, tt.want, rr.Body)
+			}
+		})
+	}
+}
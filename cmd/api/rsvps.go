@@ -0,0 +1,332 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+var (
+	errRSVPDisabled    = errors.New("the event does not take RSVPs")
+	errNoOccurrence    = errors.New("the event has no occurrence at this start")
+	errOccurrenceEnded = errors.New("the occurrence has ended")
+)
+
+// rsvpInstance returns the occurrence of event the rule starts at start, if
+// it still takes RSVPs at now.
+func rsvpInstance(event data.Event, start, now time.Time) (*data.EventInstance, error) {
+	if !event.RSVP {
+		return nil, errRSVPDisabled
+	}
+
+	set, err := eventRule(event)
+	if err != nil {
+		return nil, err
+	}
+	if !occursAt(set, start) {
+		return nil, errNoOccurrence
+	}
+
+	instance, err := newEventInstance(event, start)
+	if err != nil {
+		return nil, err
+	}
+	if !instance.EndDate.After(now) {
+		return nil, errOccurrenceEnded
+	}
+
+	return &instance, nil
+}
+
+// attendance returns the RSVP counts of the occurrence, nil when the event
+// does not take RSVPs.
+func (app *application) attendance(event data.Event, instance data.EventInstance) (*data.Attendance, error) {
+	if !event.RSVP {
+		return nil, nil
+	}
+
+	a, err := app.models.RSVPs.GetAttendance(event.ID, instance.OriginalStart, event.Capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// refreshAttendance edits the announcements of the occurrence, in the
+// background, for them to show its current attendance.
+func (app *application) refreshAttendance(event data.Event, instance data.EventInstance) {
+	announced, err := app.announcedJobs(event)
+	if err != nil {
+		app.logger.Error("Unable to get announcements", "event_id", event.ID, "error", err)
+		return
+	}
+
+	var jobs []data.Job
+	for _, job := range announced {
+		if job.OccurrenceDate.Equal(instance.StartDate) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	app.reviseAnnouncements(event, jobs, false)
+}
+
+// rsvpCustomID identifies the RSVP button answering status to the occurrence
+// of the event its rule starts at occurrence.
+func rsvpCustomID(status string, eventID uuid.UUID, occurrence time.Time) string {
+	return fmt.Sprintf("rsvp:%s:%s:%d", status, eventID, occurrence.Unix())
+}
+
+func parseRSVPCustomID(customID string) (status string, eventID uuid.UUID, occurrence time.Time, err error) {
+	parts := strings.Split(customID, ":")
+	if len(parts) != 4 || parts[0] != "rsvp" {
+		return "", uuid.Nil, time.Time{}, fmt.Errorf("invalid RSVP button %q", customID)
+	}
+
+	eventID, err = uuid.Parse(parts[2])
+	if err != nil {
+		return "", uuid.Nil, time.Time{}, fmt.Errorf("invalid RSVP button %q: %w", customID, err)
+	}
+
+	seconds, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", uuid.Nil, time.Time{}, fmt.Errorf("invalid RSVP button %q: %w", customID, err)
+	}
+
+	return parts[1], eventID, time.Unix(seconds, 0).UTC(), nil
+}
+
+// rsvpButtonClicked records the answer of the Discord user clicking an RSVP
+// button of an announcement, as their account's when it is linked. The
+// announcements of the occurrence are edited with the new counts.
+func (app *application) rsvpButtonClicked(interaction Interaction) InteractionResponseData {
+	status, eventID, occurrence, err := parseRSVPCustomID(interaction.Data.CustomID)
+	if err != nil {
+		return ephemeral("Unknown button.")
+	}
+
+	discordID := interaction.discordUserID()
+	if discordID == "" {
+		return ephemeral("Unknown Discord user.")
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ephemeral("This event no longer exists.")
+		}
+		return app.commandError("rsvp", err)
+	}
+
+	instance, err := rsvpInstance(event, occurrence, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, errRSVPDisabled):
+			return ephemeral("**%s** does not take RSVPs anymore.", event.Title)
+		case errors.Is(err, errNoOccurrence):
+			return ephemeral("This occurrence of **%s** is cancelled.", event.Title)
+		case errors.Is(err, errOccurrenceEnded):
+			return ephemeral("This occurrence of **%s** has ended.", event.Title)
+		default:
+			return app.commandError("rsvp", err)
+		}
+	}
+
+	rsvp := &data.RSVP{
+		EventID:        event.ID,
+		OccurrenceDate: instance.OriginalStart,
+		Status:         status,
+	}
+
+	user, err := app.interactionUser(interaction)
+	switch {
+	case err == nil:
+		rsvp.UserID = &user.ID
+	case errors.Is(err, errAccountNotLinked), errors.Is(err, errAccountNotActivated):
+		rsvp.DiscordUserID = discordID
+	default:
+		return app.commandError("rsvp", err)
+	}
+
+	v := validator.New()
+	if data.ValidateRSVP(v, rsvp); !v.Valid() {
+		return ephemeral("Unknown button.")
+	}
+
+	err = app.models.RSVPs.Upsert(rsvp, event.Capacity)
+	if err != nil {
+		return app.commandError("rsvp", err)
+	}
+
+	app.refreshAttendance(event, *instance)
+
+	when := discordTimestamp(instance.StartDate, "F")
+
+	switch {
+	case rsvp.Waitlisted:
+		return ephemeral("**%s** is full on %s, you are on the waitlist.", instance.Title, when)
+	case rsvp.Status == data.RSVPYes:
+		return ephemeral("You are going to **%s** on %s.", instance.Title, when)
+	case rsvp.Status == data.RSVPMaybe:
+		return ephemeral("You might go to **%s** on %s.", instance.Title, when)
+	default:
+		return ephemeral("You are not going to **%s** on %s.", instance.Title, when)
+	}
+}
+
+// readRSVPOccurrence reads the event and the occurrence of the request's
+// :id and :start, and writes the error response when they do not take RSVPs.
+func (app *application) readRSVPOccurrence(w http.ResponseWriter, r *http.Request) (data.Event, *data.EventInstance, bool) {
+	eventID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return data.Event{}, nil, false
+	}
+
+	start, err := app.readStartParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return data.Event{}, nil, false
+	}
+
+	event, err := app.models.Events.Get(eventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return data.Event{}, nil, false
+	}
+
+	instance, err := rsvpInstance(event, start, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, errNoOccurrence):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, errRSVPDisabled), errors.Is(err, errOccurrenceEnded):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return data.Event{}, nil, false
+	}
+
+	return event, instance, true
+}
+
+// listRSVPsHandler returns the RSVPs to the occurrence of the event the rule
+// starts at :start, along with their counts.
+func (app *application) listRSVPsHandler(w http.ResponseWriter, r *http.Request) {
+	event, instance, ok := app.readRSVPOccurrence(w, r)
+	if !ok {
+		return
+	}
+
+	rsvps, err := app.models.RSVPs.GetAllForOccurrence(event.ID, instance.OriginalStart, event.Capacity)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	attendance, err := app.attendance(event, *instance)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"rsvps": rsvps, "attendance": attendance}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// rsvpHandler records the answer of the authenticated user to the
+// occurrence, replacing their previous one.
+func (app *application) rsvpHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, instance, ok := app.readRSVPOccurrence(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	rsvp := &data.RSVP{
+		EventID:        event.ID,
+		OccurrenceDate: instance.OriginalStart,
+		UserID:         &user.ID,
+		Status:         input.Status,
+	}
+
+	v := validator.New()
+	if data.ValidateRSVP(v, rsvp); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.RSVPs.Upsert(rsvp, event.Capacity)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.refreshAttendance(event, *instance)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"rsvp": rsvp}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteRSVPHandler withdraws the answer of the authenticated user to the
+// occurrence, the first waitlisted user taking their place.
+func (app *application) deleteRSVPHandler(w http.ResponseWriter, r *http.Request) {
+	event, instance, ok := app.readRSVPOccurrence(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err := app.models.RSVPs.Delete(&data.RSVP{
+		EventID:        event.ID,
+		OccurrenceDate: instance.OriginalStart,
+		UserID:         &user.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.refreshAttendance(event, *instance)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "RSVP deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRSVPCustomID(t *testing.T) {
+	eventID := uuid.MustParse("0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11")
+	occurrence := time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		customID       string
+		wantStatus     string
+		wantEventID    uuid.UUID
+		wantOccurrence time.Time
+		wantErr        bool
+	}{
+		{
+			name:           "yes",
+			customID:       rsvpCustomID("yes", eventID, occurrence),
+			wantStatus:     "yes",
+			wantEventID:    eventID,
+			wantOccurrence: occurrence,
+		},
+		{
+			name:           "other time zone",
+			customID:       rsvpCustomID("maybe", eventID, occurrence.In(time.FixedZone("", 2*3600))),
+			wantStatus:     "maybe",
+			wantEventID:    eventID,
+			wantOccurrence: occurrence,
+		},
+		{
+			name:           "literal",
+			customID:       "rsvp:no:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11:1748894400",
+			wantStatus:     "no",
+			wantEventID:    eventID,
+			wantOccurrence: occurrence,
+		},
+		{name: "other component", customID: "poll:yes:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11:1748894400", wantErr: true},
+		{name: "missing part", customID: "rsvp:yes:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11", wantErr: true},
+		{name: "extra part", customID: "rsvp:yes:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11:1748894400:1", wantErr: true},
+		{name: "invalid event", customID: "rsvp:yes:raid:1748894400", wantErr: true},
+		{name: "invalid occurrence", customID: "rsvp:yes:0b6f1a7e-3f43-4d38-9c36-5f0a2c0e8a11:tomorrow", wantErr: true},
+		{name: "empty", customID: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, id, start, err := parseRSVPCustomID(tt.customID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if status != tt.wantStatus || id != tt.wantEventID || !start.Equal(tt.wantOccurrence) {
+				t.Errorf("got (%q, %s, %s), want (%q, %s, %s)", status, id, start, tt.wantStatus, tt.wantEventID, tt.wantOccurrence)
+			}
+			if start.Location() != time.UTC {
+				t.Errorf("got the occurrence in %s, want UTC", start.Location())
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+// scheduledEventStore records the scheduled events mirroring the occurrences
+// of the events, as data.ScheduledEventModel does.
+type scheduledEventStore interface {
+	Reserve(eventID uuid.UUID, occurrenceDate time.Time) (bool, error)
+	SetDiscordID(eventID uuid.UUID, occurrenceDate time.Time, discordID string) error
+	Delete(eventID uuid.UUID, occurrenceDate time.Time) error
+}
+
+// startScheduledEventSync mirrors the occurrences of the active events in
+// the guild's scheduled events in the background every sync interval until
+// ctx is cancelled, so that the occurrences entering the horizon appear in
+// Discord. The changes made to the events are mirrored as they happen.
+func (app *application) startScheduledEventSync(ctx context.Context) error {
+	if !app.mirrorsScheduledEvents() {
+		return nil
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(app.bot.syncInterval)
+		defer ticker.Stop()
+
+		for {
+			events, err := app.models.Events.GetActiveEvents()
+			if err != nil {
+				app.logger.Error("Unable to get active events", "error", err)
+			}
+
+			for _, event := range events {
+				if ctx.Err() != nil {
+					return
+				}
+
+				scheduled, err := app.models.Scheduled.GetAllForEvent(event.ID)
+				if err != nil {
+					app.logger.Error("Unable to get scheduled events", "event_id", event.ID, "error", err)
+					continue
+				}
+
+				app.syncScheduledEvents(ctx, app.models.Scheduled, event, scheduled, false)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	return nil
+}
+
+func (app *application) mirrorsScheduledEvents() bool {
+	return app.bot != nil && app.bot.scheduledEvents
+}
+
+// refreshScheduledEvents mirrors the changes made to event in the guild's
+// scheduled events, in the background.
+func (app *application) refreshScheduledEvents(event data.Event) {
+	if !app.mirrorsScheduledEvents() {
+		return
+	}
+
+	app.background(func() {
+		scheduled, err := app.models.Scheduled.GetAllForEvent(event.ID)
+		if err != nil {
+			app.logger.Error("Unable to get scheduled events", "event_id", event.ID, "error", err)
+			return
+		}
+
+		app.syncScheduledEvents(context.Background(), app.models.Scheduled, event, scheduled, true)
+	})
+}
+
+// removeScheduledEvents deletes the scheduled events of a deleted event, in
+// the background. They are looked up before the event is deleted, along with
+// them.
+func (app *application) removeScheduledEvents(event data.Event, scheduled []data.ScheduledEvent) {
+	if !app.mirrorsScheduledEvents() || len(scheduled) == 0 {
+		return
+	}
+
+	event.IsActive = false
+
+	app.background(func() {
+		app.syncScheduledEvents(context.Background(), app.models.Scheduled, event, scheduled, false)
+	})
+}
+
+// syncScheduledEvents creates the scheduled events of the occurrences of event
+// starting within the horizon, and edits the existing ones when edit is set.
+// The scheduled events of the occurrences no longer scheduled are deleted,
+// the ones which already started are left to Discord. The scheduled events
+// are keyed on the start the rule gives their occurrence, which stays the
+// same when the occurrence is moved.
+func (app *application) syncScheduledEvents(ctx context.Context, store scheduledEventStore, event data.Event, scheduled []data.ScheduledEvent, edit bool) {
+	now := time.Now()
+
+	var instances []data.EventInstance
+	if event.IsActive {
+		var err error
+		instances, err = expandEvent(event, now, now.Add(app.bot.horizon))
+		if err != nil {
+			app.logger.Error("Unable to expand event", "event_id", event.ID, "error", err)
+			return
+		}
+	}
+
+	for _, instance := range instances {
+		i := slices.IndexFunc(scheduled, func(s data.ScheduledEvent) bool {
+			return s.OccurrenceDate.Equal(instance.OriginalStart)
+		})
+
+		if i < 0 {
+			app.createScheduledEvent(ctx, store, instance)
+			continue
+		}
+
+		s := scheduled[i]
+		scheduled = slices.Delete(scheduled, i, i+1)
+
+		// A scheduled event without Discord ID is being created by another
+		// synchronization.
+		if edit && s.DiscordID != "" {
+			err := app.bot.EditScheduledEvent(ctx, s.DiscordID, app.guildScheduledEvent(instance))
+			if err != nil {
+				app.logger.Error("Unable to edit scheduled event", "event_id", event.ID, "discord_id", s.DiscordID, "error", err)
+			}
+		}
+	}
+
+	// Forgetting a scheduled event being created makes its creator delete it
+	// once created.
+	for _, s := range scheduled {
+		if s.DiscordID != "" && s.OccurrenceDate.After(now) {
+			err := app.bot.DeleteScheduledEvent(ctx, s.DiscordID)
+			if err != nil {
+				app.logger.Error("Unable to delete scheduled event", "event_id", event.ID, "discord_id", s.DiscordID, "error", err)
+				continue
+			}
+		}
+
+		err := store.Delete(s.EventID, s.OccurrenceDate)
+		if err != nil {
+			app.logger.Error("Unable to forget scheduled event", "event_id", event.ID, "discord_id", s.DiscordID, "error", err)
+		}
+	}
+}
+
+// createScheduledEvent creates the scheduled event of instance, once its
+// occurrence is reserved, so that concurrent synchronizations create it only
+// once. The reservation is withdrawn when Discord fails to create it.
+func (app *application) createScheduledEvent(ctx context.Context, store scheduledEventStore, instance data.EventInstance) {
+	reserved, err := store.Reserve(instance.EventID, instance.OriginalStart)
+	if err != nil {
+		app.logger.Error("Unable to reserve scheduled event", "event_id", instance.EventID, "occurrence", instance.OriginalStart, "error", err)
+		return
+	}
+	if !reserved {
+		return
+	}
+
+	id, err := app.bot.CreateScheduledEvent(ctx, app.guildScheduledEvent(instance))
+	if err != nil {
+		app.logger.Error("Unable to create scheduled event", "event_id", instance.EventID, "occurrence", instance.OriginalStart, "error", err)
+
+		err = store.Delete(instance.EventID, instance.OriginalStart)
+		if err != nil {
+			app.logger.Error("Unable to withdraw scheduled event reservation", "event_id", instance.EventID, "occurrence", instance.OriginalStart, "error", err)
+		}
+		return
+	}
+
+	err = store.SetDiscordID(instance.EventID, instance.OriginalStart, id)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		// The occurrence was unscheduled while its scheduled event was
+		// created.
+		err = app.bot.DeleteScheduledEvent(ctx, id)
+		if err != nil {
+			app.logger.Error("Unable to delete scheduled event", "event_id", instance.EventID, "discord_id", id, "error", err)
+		}
+	case err != nil:
+		app.logger.Error("Unable to save scheduled event", "event_id", instance.EventID, "discord_id", id, "error", err)
+	}
+}
+
+// guildScheduledEvent returns the scheduled event mirroring instance, within
+// Discord's limits.
+func (app *application) guildScheduledEvent(instance data.EventInstance) GuildScheduledEvent {
+	return GuildScheduledEvent{
+		Name:               truncate(100, instance.Title),
+		Description:        truncate(1000, instance.Description),
+		ScheduledStartTime: instance.StartDate.UTC(),
+		ScheduledEndTime:   instance.EndDate.UTC(),
+		PrivacyLevel:       scheduledEventGuildOnly,
+		EntityType:         scheduledEventExternal,
+		EntityMetadata:     &GuildScheduledEventMetadata{Location: truncate(100, app.bot.location)},
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/google/uuid"
+)
+
+// fakeDiscordAPI serves the scheduled events routes of the Discord REST API
+// and records the requests made to it.
+type fakeDiscordAPI struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]GuildScheduledEvent
+	// createStatus, when set, is the status the creations fail with.
+	createStatus int
+}
+
+func (f *fakeDiscordAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	request := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, request)
+
+	var event GuildScheduledEvent
+	_ = json.NewDecoder(r.Body).Decode(&event)
+	f.bodies[request] = event
+
+	switch {
+	case r.Method == http.MethodPost && f.createStatus != 0:
+		w.WriteHeader(f.createStatus)
+		fmt.Fprint(w, `{"message": "Invalid Form Body"}`)
+	case r.Method == http.MethodPost:
+		event.ID = fmt.Sprintf("created-%d", len(f.requests))
+		json.NewEncoder(w).Encode(event)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+// fakeScheduledStore keeps the scheduled events by occurrence, an empty
+// Discord ID standing for a reservation.
+type fakeScheduledStore struct {
+	rows map[time.Time]string
+	// held are the occurrences another synchronization is mirroring.
+	held map[time.Time]bool
+	// withdrawn makes the reservations disappear before their Discord ID
+	// is recorded.
+	withdrawn bool
+}
+
+func (f *fakeScheduledStore) Reserve(eventID uuid.UUID, occurrenceDate time.Time) (bool, error) {
+	if _, exists := f.rows[occurrenceDate]; exists || f.held[occurrenceDate] {
+		return false, nil
+	}
+
+	f.rows[occurrenceDate] = ""
+
+	return true, nil
+}
+
+func (f *fakeScheduledStore) SetDiscordID(eventID uuid.UUID, occurrenceDate time.Time, discordID string) error {
+	if f.withdrawn {
+		delete(f.rows, occurrenceDate)
+	}
+
+	if id, exists := f.rows[occurrenceDate]; !exists || id != "" {
+		return data.ErrRecordNotFound
+	}
+
+	f.rows[occurrenceDate] = discordID
+
+	return nil
+}
+
+func (f *fakeScheduledStore) Delete(eventID uuid.UUID, occurrenceDate time.Time) error {
+	delete(f.rows, occurrenceDate)
+	return nil
+}
+
+func newScheduledEventsTest(t *testing.T) (*application, *fakeDiscordAPI) {
+	t.Helper()
+
+	api := &fakeDiscordAPI{bodies: make(map[string]GuildScheduledEvent)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	var cfg config
+	cfg.Discord.BotToken = "token"
+	cfg.Discord.GuildID = "guild"
+	cfg.Discord.APIURL = server.URL
+	cfg.Discord.ScheduledEvents.Enabled = true
+	cfg.Discord.ScheduledEvents.Horizon = "168h"
+	cfg.Discord.ScheduledEvents.SyncInterval = "15m"
+
+	bot, err := setupDiscordBot(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication()
+	app.bot = bot
+
+	return app, api
+}
+
+// dailyEvent returns an event occurring on the next three days, its first
+// occurrence starting in an hour.
+func dailyEvent() data.Event {
+	return data.Event{
+		ID:          uuid.New(),
+		Title:       "Raid night",
+		Description: "Raid night",
+		Duration:    "PT1H",
+		RRule:       "FREQ=DAILY;COUNT=3",
+		StartDate:   time.Now().Add(time.Hour).Truncate(time.Second).UTC(),
+		Timezone:    "UTC",
+		IsActive:    true,
+	}
+}
+
+func TestSyncScheduledEvents(t *testing.T) {
+	app, api := newScheduledEventsTest(t)
+
+	event := dailyEvent()
+	first := event.StartDate
+	second := first.AddDate(0, 0, 1)
+	third := first.AddDate(0, 0, 2)
+
+	moved := second.Add(2 * time.Hour)
+	event.Overrides = []data.OccurrenceOverride{{EventID: event.ID, OriginalStart: second, StartDate: &moved}}
+
+	store := &fakeScheduledStore{
+		rows: map[time.Time]string{
+			second:                 "moved",
+			first.AddDate(0, 0, 5): "unscheduled",
+			first.AddDate(0, 0, 6): "",
+		},
+		held: map[time.Time]bool{third: true},
+	}
+
+	var scheduled []data.ScheduledEvent
+	for occurrence, id := range store.rows {
+		scheduled = append(scheduled, data.ScheduledEvent{EventID: event.ID, OccurrenceDate: occurrence, DiscordID: id})
+	}
+
+	app.syncScheduledEvents(context.Background(), store, event, scheduled, true)
+
+	requests := slices.Sorted(slices.Values(api.requests))
+	want := []string{
+		"DELETE /guilds/guild/scheduled-events/unscheduled",
+		"PATCH /guilds/guild/scheduled-events/moved",
+		"POST /guilds/guild/scheduled-events",
+	}
+	if !slices.Equal(requests, want) {
+		t.Fatalf("got requests %v, want %v", requests, want)
+	}
+
+	edited := api.bodies["PATCH /guilds/guild/scheduled-events/moved"]
+	if !edited.ScheduledStartTime.Equal(moved) {
+		t.Errorf("got the moved occurrence starting at %s, want %s", edited.ScheduledStartTime, moved)
+	}
+
+	created := api.bodies["POST /guilds/guild/scheduled-events"]
+	if !created.ScheduledStartTime.Equal(first) {
+		t.Errorf("got the created occurrence starting at %s, want %s", created.ScheduledStartTime, first)
+	}
+
+	wantRows := map[time.Time]string{first: "created-1", second: "moved"}
+	if fmt.Sprint(store.rows) != fmt.Sprint(wantRows) {
+		t.Errorf("got scheduled events %v, want %v", store.rows, wantRows)
+	}
+}
+
+func TestSyncScheduledEventsCreationFailure(t *testing.T) {
+	app, api := newScheduledEventsTest(t)
+	api.createStatus = http.StatusBadRequest
+
+	event := dailyEvent()
+	store := &fakeScheduledStore{rows: make(map[time.Time]string)}
+
+	app.syncScheduledEvents(context.Background(), store, event, nil, false)
+
+	if len(api.requests) != 3 {
+		t.Errorf("got requests %v, want the three occurrences created", api.requests)
+	}
+	if len(store.rows) != 0 {
+		t.Errorf("got scheduled events %v, want the reservations withdrawn", store.rows)
+	}
+}
+
+func TestSyncScheduledEventsUnscheduledDuringCreation(t *testing.T) {
+	app, api := newScheduledEventsTest(t)
+
+	event := dailyEvent()
+	event.RRule = "FREQ=DAILY;COUNT=1"
+	store := &fakeScheduledStore{rows: make(map[time.Time]string), withdrawn: true}
+
+	app.syncScheduledEvents(context.Background(), store, event, nil, false)
+
+	want := []string{
+		"POST /guilds/guild/scheduled-events",
+		"DELETE /guilds/guild/scheduled-events/created-1",
+	}
+	if !slices.Equal(api.requests, want) {
+		t.Errorf("got requests %v, want the scheduled event deleted once created", api.requests)
+	}
+}
@@ -1,17 +1,79 @@
 package main
 
-import "github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+import (
+	"context"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+)
 
 type Scheduler interface {
-	Execute(event data.Event)
+	Execute(ctx context.Context, event data.Event, webhook *data.Webhook, reminder *data.Reminder, occurrence time.Time) (string, error)
+}
+
+// Execute sends the reminder of the occurrence of event starting at
+// occurrence to webhook. The reminder is nil for events without reminders.
+// It returns the ID of the message sent, empty when the channel cannot edit
+// its messages. The message is sent within ctx.
+func (app *application) Execute(ctx context.Context, event data.Event, webhook *data.Webhook, reminder *data.Reminder, occurrence time.Time) (string, error) {
+	instance, err := scheduledInstance(event, occurrence)
+	if err != nil {
+		app.logger.Error("Unable to expand event", "event_id", event.ID, "error", err)
+		return "", err
+	}
+	if instance == nil {
+		// The occurrence was moved or cancelled after the job was
+		// materialized.
+		app.logger.Warn("Occurrence no longer scheduled, skipping", "event_id", event.ID, "occurrence", occurrence)
+		return "", nil
+	}
+
+	msg, err := app.message(event, instance, reminder, messageTemplate(event, webhook, reminder))
+	if err != nil {
+		app.logger.Error("Unable to build message", "event_id", event.ID, "error", err)
+		return "", err
+	}
+
+	resp, err := app.notify(ctx, webhook, msg)
+	if err != nil {
+		app.logger.Error("Unable to send message", "event_id", event.ID, "webhook_id", webhook.ID, "error", err)
+		return "", err
+	}
+
+	app.logger.Info("Message sent successfully", "event_id", event.ID, "webhook_id", webhook.ID, "channel_type", webhook.ChannelType)
+	return resp.MessageID, nil
 }
 
-func (app *application) Execute(event data.Event) {
-	msg := FormatMessage(event)
-	err := app.SendMessage(msg, event.Title, event.WebhookID)
+// message builds the message announcing instance, an occurrence of event, as
+// it gets sent: rendered with tmpl and showing the attendance of the
+// occurrence. The dry runs and previews use it too, for them to show what
+// gets sent.
+func (app *application) message(event data.Event, instance *data.EventInstance, reminder *data.Reminder, tmpl *data.MessageTemplate) (Message, error) {
+	msg, err := NewMessage(event, instance, reminder, tmpl)
 	if err != nil {
-		app.logger.Error("Unable to send message", err.Error())
-	} else {
-		app.logger.Info("Message sent successfully")
+		return Message{}, err
+	}
+
+	if instance != nil {
+		msg.Attendance, err = app.attendance(event, *instance)
+		if err != nil {
+			return Message{}, err
+		}
 	}
+
+	return msg, nil
+}
+
+// scheduledInstance returns the occurrence of event starting at occurrence,
+// or nil when the event no longer occurs then.
+func scheduledInstance(event data.Event, occurrence time.Time) (*data.EventInstance, error) {
+	instance, err := nextInstance(event, occurrence.Add(-time.Second))
+	if err != nil {
+		return nil, err
+	}
+	if instance == nil || !instance.StartDate.Equal(occurrence) {
+		return nil, nil
+	}
+
+	return instance, nil
 }
@@ -20,6 +20,26 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	ctx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+
+	err := app.startScheduler(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	err = app.startCalendarSync(ctx, app.calendars)
+	if err != nil {
+		return err
+	}
+
+	err = app.startScheduledEventSync(ctx)
+	if err != nil {
+		return err
+	}
+
+	app.registerCommands()
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -27,9 +47,7 @@ func (app *application) serve() error {
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
 
-		app.logger.Info("caught signal", map[string]string{
-			"signal": s.String(),
-		})
+		app.logger.Info("caught signal", "signal", s.String())
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -39,10 +57,9 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 
-		app.logger.Info("completing background tasks", map[string]string{
-			"addr": srv.Addr,
-		})
+		app.logger.Info("completing background tasks", "addr", srv.Addr)
 
+		stopScheduler()
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -52,7 +69,7 @@ func (app *application) serve() error {
 		"env":  app.config.Env,
 	})
 
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
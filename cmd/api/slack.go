@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type slackBody struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type      string      `json:"type"`
+	Text      *slackText  `json:"text,omitempty"`
+	Fields    []slackText `json:"fields,omitempty"`
+	Elements  []slackText `json:"elements,omitempty"`
+	Accessory *slackImage `json:"accessory,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackImage struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+// slackMaxFields is the number of fields a Slack section holds.
+const slackMaxFields = 10
+
+// slackNotifier posts messages to Slack incoming webhooks as blocks. The
+// start date is rendered by Slack in the reader's time zone. The text of the
+// message is escaped, Slack would otherwise turn <!channel> and the like in
+// it into mentions.
+type slackNotifier struct {
+	client *http.Client
+}
+
+func (n slackNotifier) Payload(msg Message) any {
+	body := slackBody{Text: slackEscape(msg.Content)}
+
+	if msg.Author.Name != "" {
+		body.Blocks = append(body.Blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: slackLink(msg.Author.URL, msg.Author.Name)}},
+		})
+	}
+
+	body.Blocks = append(body.Blocks, slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: msg.Title},
+	})
+
+	description := slackEscape(msg.Description)
+	if msg.URL != "" {
+		description += "\n" + slackLink(msg.URL, msg.URL)
+	}
+	if description != "" {
+		section := slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: description},
+		}
+		if msg.ThumbnailURL != "" {
+			section.Accessory = &slackImage{Type: "image", ImageURL: msg.ThumbnailURL, AltText: msg.Title}
+		}
+		body.Blocks = append(body.Blocks, section)
+	}
+
+	if len(msg.Fields) > 0 {
+		section := slackBlock{Type: "section"}
+		for _, field := range msg.Fields[:min(len(msg.Fields), slackMaxFields)] {
+			section.Fields = append(section.Fields, slackText{Type: "mrkdwn", Text: "*" + slackEscape(field.Name) + "*\n" + slackEscape(field.Value)})
+		}
+		body.Blocks = append(body.Blocks, section)
+	}
+
+	var footer []slackText
+	if !msg.StartDate.IsZero() {
+		date := fmt.Sprintf("<!date^%d^{date_long_pretty} at {time}|%s>", msg.StartDate.Unix(), announcementTime(msg.StartDate))
+		footer = append(footer, slackText{Type: "mrkdwn", Text: date})
+	}
+	if msg.Footer != "" {
+		footer = append(footer, slackText{Type: "mrkdwn", Text: slackEscape(msg.Footer)})
+	}
+	if len(footer) > 0 {
+		body.Blocks = append(body.Blocks, slackBlock{Type: "context", Elements: footer})
+	}
+
+	return body
+}
+
+func (n slackNotifier) Send(ctx context.Context, url string, payload any) (*notifyResponse, error) {
+	return sendJSON(ctx, n.client, http.MethodPost, url, payload)
+}
+
+func slackLink(url, text string) string {
+	if url == "" {
+		return slackEscape(text)
+	}
+
+	return "<" + slackEscape(url) + "|" + slackEscape(text) + ">"
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes the characters Slack's mrkdwn gives a meaning to.
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
@@ -10,8 +10,9 @@ import (
 
 func (app *application) createTagHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Mentions    data.Mentions `json:"mentions"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -23,6 +24,7 @@ func (app *application) createTagHandler(w http.ResponseWriter, r *http.Request)
 	tag := &data.Tag{
 		Name:        input.Name,
 		Description: input.Description,
+		Mentions:    input.Mentions,
 	}
 
 	v := validator.New()
@@ -77,8 +79,9 @@ func (app *application) updateTagHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Mentions    *data.Mentions `json:"mentions"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -103,6 +106,9 @@ func (app *application) updateTagHandler(w http.ResponseWriter, r *http.Request)
 	if input.Name != "" {
 		currentTag.Name = input.Name
 	}
+	if input.Mentions != nil {
+		currentTag.Mentions = *input.Mentions
+	}
 
 	v := validator.New()
 	data.ValidateTag(v, currentTag)
@@ -149,22 +155,31 @@ func (app *application) deleteTagHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (app *application) listTagsHandler(w http.ResponseWriter, r *http.Request) {
-	tags, err := app.models.Tags.GetAll()
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "created_date", "-id", "-name", "-created_date"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if len(tags) == 0 {
-		err := app.writeJSON(w, http.StatusNoContent, envelope{"tags": []data.Tag{}}, nil)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
+	tags, metadata, err := app.models.Tags.GetAll(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
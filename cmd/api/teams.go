@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type teamsBody struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []teamsElement `json:"body"`
+	Actions []teamsAction  `json:"actions,omitempty"`
+}
+
+type teamsElement struct {
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Size     string      `json:"size,omitempty"`
+	Weight   string      `json:"weight,omitempty"`
+	IsSubtle bool        `json:"isSubtle,omitempty"`
+	Wrap     bool        `json:"wrap,omitempty"`
+	Facts    []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// teamsNotifier posts messages to Microsoft Teams webhooks as an Adaptive
+// Card. The dates are rendered by Teams in the reader's time zone.
+type teamsNotifier struct {
+	client *http.Client
+}
+
+func (n teamsNotifier) Payload(msg Message) any {
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+	}
+
+	if msg.Author.Name != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Author.Name, Size: "Small", IsSubtle: true})
+	}
+	if msg.ThumbnailURL != "" {
+		card.Body = append(card.Body, teamsElement{Type: "Image", URL: msg.ThumbnailURL, Size: "Small"})
+	}
+
+	card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Title, Size: "Medium", Weight: "Bolder", Wrap: true})
+
+	if msg.Description != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Description, Wrap: true})
+	}
+
+	var facts []teamsFact
+	if !msg.StartDate.IsZero() {
+		facts = append(facts, teamsFact{Title: "Starts", Value: teamsDate(msg.StartDate)})
+		if msg.EndDate.After(msg.StartDate) {
+			facts = append(facts, teamsFact{Title: "Ends", Value: teamsDate(msg.EndDate)})
+		}
+	}
+	for _, field := range msg.Fields {
+		facts = append(facts, teamsFact{Title: field.Name, Value: field.Value})
+	}
+	if len(facts) > 0 {
+		card.Body = append(card.Body, teamsElement{Type: "FactSet", Facts: facts})
+	}
+
+	if msg.Footer != "" {
+		card.Body = append(card.Body, teamsElement{Type: "TextBlock", Text: msg.Footer, Size: "Small", IsSubtle: true, Wrap: true})
+	}
+
+	if msg.URL != "" {
+		card.Actions = append(card.Actions, teamsAction{Type: "Action.OpenUrl", Title: "Open", URL: msg.URL})
+	}
+
+	return teamsBody{
+		Type: "message",
+		Attachments: []teamsAttachment{
+			{ContentType: "application/vnd.microsoft.card.adaptive", Content: card},
+		},
+	}
+}
+
+func (n teamsNotifier) Send(ctx context.Context, url string, payload any) (*notifyResponse, error) {
+	return sendJSON(ctx, n.client, http.MethodPost, url, payload)
+}
+
+// teamsDate formats t with the Adaptive Card date and time functions.
+func teamsDate(t time.Time) string {
+	s := t.UTC().Format(time.RFC3339)
+	return fmt.Sprintf("{{DATE(%s, LONG)}} {{TIME(%s)}}", s, s)
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// The limits of the execution of a message template. Every part of the
+// message shares the deadline, the size limit applies to each of them.
+const (
+	templateTimeout   = 100 * time.Millisecond
+	maxTemplateOutput = 16 << 10
+)
+
+var (
+	errTemplateTimeout  = errors.New("takes too long to render")
+	errTemplateTooLarge = errors.New("renders too much text")
+)
+
+// messageLimits are the lengths, in characters, Discord accepts for the
+// parts of a message. The embed one is Discord's 6000 for the whole embed,
+// less room for the attendance fields added to it.
+var messageLimits = struct {
+	content, title, description, fieldName, fieldValue, footer, authorName, embed int
+}{
+	content:     2000,
+	title:       256,
+	description: 4096,
+	fieldName:   256,
+	fieldValue:  1024,
+	footer:      2048,
+	authorName:  256,
+	embed:       5900,
+}
+
+// deadlineCheck is the {{deadline}} action added at the start of the
+// templates and of the bodies of their loops, so that loops and recursions
+// writing nothing still stop at the deadline.
+var deadlineCheck = func() parse.Node {
+	t := template.Must(template.New("deadline").Funcs(template.FuncMap{
+		"deadline": func() (string, error) { return "", nil },
+	}).Parse("{{deadline}}"))
+
+	return t.Tree.Root.Nodes[0]
+}()
+
+// defaultTemplate is the message announcing an event when neither the event
+// nor its webhook customizes it.
+var defaultTemplate = data.MessageTemplate{
+	Content:     "{{.Event.Title}}",
+	Title:       "{{.Title}}",
+	Description: "{{.Description}}",
+	// https://gist.github.com/thomasbnt/b6f455e2c7d743b796917fa3c205f812
+	Color: "#E67E22",
+}
+
+// defaultEndedTemplate is the default message of the reminders sent relative
+// to the end of an occurrence.
+var defaultEndedTemplate = data.MessageTemplate{
+	Content:     "{{.Event.Title}} has ended",
+	Title:       "{{.Title}}",
+	Description: "{{.Description}}",
+	Color:       "#95A5A6",
+}
+
+// templateData is what the message templates are executed against. Title
+// and Description are the occurrence's, which an override may change from
+// the event's. Start and End are in the event's time zone, and zero when the
+// event has no upcoming occurrence. Reminder is nil for the announcements of
+// events without reminders.
+type templateData struct {
+	Event       data.Event
+	Reminder    *data.Reminder
+	Title       string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Tags        []string
+	Countdown   string
+}
+
+var templateFuncs = template.FuncMap{
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"timestamp": discordTimestamp,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"join":      strings.Join,
+	"truncate":  truncate,
+}
+
+// truncate shortens s to n characters, ending it with an ellipsis when it is
+// cut.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// NewMessage builds the message announcing instance, an occurrence of event,
+// for reminder with tmpl. The default message is used when tmpl is nil, and
+// reminder is nil for the announcements of events without reminders.
+func NewMessage(event data.Event, instance *data.EventInstance, reminder *data.Reminder, tmpl *data.MessageTemplate) (Message, error) {
+	d := newTemplateData(event, instance, time.Now())
+	d.Reminder = reminder
+
+	msg, err := renderMessage(tmpl, d)
+	if instance != nil {
+		msg.OccurrenceDate = instance.OriginalStart
+	}
+
+	return msg, err
+}
+
+// messageTemplate returns the template of the reminder, else the event's,
+// else the webhook's.
+func messageTemplate(event data.Event, webhook *data.Webhook, reminder *data.Reminder) *data.MessageTemplate {
+	if reminder != nil && reminder.Template != nil {
+		return reminder.Template
+	}
+	if event.Template != nil {
+		return event.Template
+	}
+
+	return webhook.Template
+}
+
+func newTemplateData(event data.Event, instance *data.EventInstance, now time.Time) templateData {
+	d := templateData{
+		Event:       event,
+		Title:       event.Title,
+		Description: event.Description,
+		Tags:        make([]string, len(event.Tags)),
+	}
+
+	for i, tag := range event.Tags {
+		d.Tags[i] = tag.Name
+	}
+
+	if instance != nil {
+		d.Title = instance.Title
+		d.Description = instance.Description
+		d.Start = instance.StartDate
+		d.End = instance.EndDate
+		d.Countdown = formatCountdown(instance.StartDate.Sub(now))
+	}
+
+	return d
+}
+
+// sampleTemplateData is an occurrence the templates are tried on when they
+// are saved.
+func sampleTemplateData() templateData {
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	event := data.Event{
+		ID:          uuid.New(),
+		Title:       "Sample event",
+		Description: "Sample description",
+		Duration:    "PT2H",
+		RRule:       "FREQ=WEEKLY",
+		StartDate:   start,
+		Timezone:    "UTC",
+		IsActive:    true,
+		Tags:        []data.Tag{{Name: "sample"}},
+	}
+
+	return newTemplateData(event, &data.EventInstance{
+		EventID:     event.ID,
+		Title:       event.Title,
+		Description: event.Description,
+		StartDate:   start,
+		EndDate:     start.Add(2 * time.Hour),
+	}, start.Add(-24*time.Hour))
+}
+
+// validateMessageTemplate executes the templates on a sample occurrence, so
+// that syntax errors and unknown fields are reported when the template is
+// saved rather than when the event is announced. The parts of the sample
+// message must fit in Discord's limits.
+func validateMessageTemplate(v *validator.Validator, tmpl *data.MessageTemplate) {
+	if tmpl == nil {
+		return
+	}
+
+	r := templateRenderer{data: sampleTemplateData()}
+
+	msg := r.message(mergeTemplate(defaultTemplate, tmpl))
+
+	checkLength := func(key, text string, limit int) {
+		if utf8.RuneCountInString(text) > limit {
+			r.addError(key, fmt.Sprintf("must not render more than %d characters", limit))
+		}
+	}
+
+	checkLength("content", msg.Content, messageLimits.content)
+	checkLength("title", msg.Title, messageLimits.title)
+	checkLength("description", msg.Description, messageLimits.description)
+	checkLength("footer", msg.Footer, messageLimits.footer)
+	for i, field := range msg.Fields {
+		checkLength(fmt.Sprintf("fields[%d].name", i), field.Name, messageLimits.fieldName)
+		checkLength(fmt.Sprintf("fields[%d].value", i), field.Value, messageLimits.fieldValue)
+	}
+
+	for key, message := range r.errors {
+		v.AddError("template."+key, message)
+	}
+}
+
+func renderMessage(tmpl *data.MessageTemplate, d templateData) (Message, error) {
+	base := defaultTemplate
+	if d.Reminder != nil && d.Reminder.RelativeTo == data.ReminderEnd {
+		base = defaultEndedTemplate
+	}
+
+	r := templateRenderer{data: d}
+
+	msg := r.message(mergeTemplate(base, tmpl))
+	if len(r.errors) > 0 {
+		return msg, fmt.Errorf("invalid message template: %v", r.errors)
+	}
+
+	clampMessage(&msg)
+
+	return msg, nil
+}
+
+// clampMessage shortens the parts of msg to Discord's limits, which an
+// occurrence may exceed even though the template fit them for the sample one
+// it was validated with. The content leaves room for the mentions prepended
+// to it, and the description is shortened further to fit the whole embed.
+func clampMessage(msg *Message) {
+	contentLimit := messageLimits.content
+	if mentions := discordMentions(msg.Mentions); mentions != "" {
+		contentLimit -= utf8.RuneCountInString(mentions) + 1
+	}
+
+	msg.Content = truncate(contentLimit, msg.Content)
+	msg.Title = truncate(messageLimits.title, msg.Title)
+	msg.Description = truncate(messageLimits.description, msg.Description)
+	msg.Footer = truncate(messageLimits.footer, msg.Footer)
+	msg.Author.Name = truncate(messageLimits.authorName, msg.Author.Name)
+
+	length := utf8.RuneCountInString(msg.Title) + utf8.RuneCountInString(msg.Footer) + utf8.RuneCountInString(msg.Author.Name)
+	for i, field := range msg.Fields {
+		msg.Fields[i].Name = truncate(messageLimits.fieldName, field.Name)
+		msg.Fields[i].Value = truncate(messageLimits.fieldValue, field.Value)
+		length += utf8.RuneCountInString(msg.Fields[i].Name) + utf8.RuneCountInString(msg.Fields[i].Value)
+	}
+
+	msg.Description = truncate(messageLimits.embed-length, msg.Description)
+}
+
+// mergeTemplate returns base with the parts set in tmpl replaced.
+func mergeTemplate(base data.MessageTemplate, tmpl *data.MessageTemplate) data.MessageTemplate {
+	t := base
+	if tmpl == nil {
+		return t
+	}
+
+	for _, s := range []struct {
+		dst *string
+		src string
+	}{
+		{&t.Content, tmpl.Content},
+		{&t.Title, tmpl.Title},
+		{&t.Description, tmpl.Description},
+		{&t.URL, tmpl.URL},
+		{&t.Color, tmpl.Color},
+		{&t.Footer, tmpl.Footer},
+		{&t.ThumbnailURL, tmpl.ThumbnailURL},
+	} {
+		if s.src != "" {
+			*s.dst = s.src
+		}
+	}
+
+	t.Fields = tmpl.Fields
+	t.Author = tmpl.Author
+
+	return t
+}
+
+// templateRenderer executes the templates of a message, keeping the errors
+// keyed by the part of the template they come from. The templates stop at
+// the deadline, set when the first of them is executed, and the ones after
+// the template reaching it are skipped.
+type templateRenderer struct {
+	data     templateData
+	errors   map[string]string
+	deadline time.Time
+	timedOut bool
+}
+
+func (r *templateRenderer) message(t data.MessageTemplate) Message {
+	msg := Message{
+		EventID:      r.data.Event.ID,
+		Content:      r.execute("content", t.Content),
+		Title:        r.execute("title", t.Title),
+		Description:  r.execute("description", t.Description),
+		URL:          r.execute("url", t.URL),
+		Footer:       r.execute("footer", t.Footer),
+		ThumbnailURL: r.execute("thumbnail_url", t.ThumbnailURL),
+		Mentions:     r.data.Event.AllMentions(),
+		StartDate:    r.data.Start,
+		EndDate:      r.data.End,
+	}
+
+	color, err := strconv.ParseInt(strings.TrimPrefix(t.Color, "#"), 16, 32)
+	if err != nil {
+		r.addError("color", "must be a hex color such as #E67E22")
+	}
+	msg.Color = int(color)
+
+	for i, field := range t.Fields {
+		msg.Fields = append(msg.Fields, MessageField{
+			Name:   r.execute(fmt.Sprintf("fields[%d].name", i), field.Name),
+			Value:  r.execute(fmt.Sprintf("fields[%d].value", i), field.Value),
+			Inline: field.Inline,
+		})
+	}
+
+	if t.Author != nil {
+		msg.Author = MessageAuthor{
+			Name:    r.execute("author.name", t.Author.Name),
+			URL:     r.execute("author.url", t.Author.URL),
+			IconURL: r.execute("author.icon_url", t.Author.IconURL),
+		}
+	}
+
+	return msg
+}
+
+func (r *templateRenderer) execute(key, text string) string {
+	if text == "" || r.timedOut {
+		return ""
+	}
+
+	if r.deadline.IsZero() {
+		r.deadline = time.Now().Add(templateTimeout)
+	}
+
+	tmpl, err := template.New(key).
+		Funcs(templateFuncs).
+		Funcs(template.FuncMap{"deadline": r.checkDeadline}).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		r.addError(key, err.Error())
+		return ""
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			guardLoops(t.Tree.Root)
+			t.Tree.Root.Nodes = slices.Insert(t.Tree.Root.Nodes, 0, deadlineCheck)
+		}
+	}
+
+	w := &limitedWriter{limit: maxTemplateOutput, deadline: r.deadline}
+	err = tmpl.Execute(w, r.data)
+	switch {
+	case errors.Is(err, errTemplateTimeout):
+		r.timedOut = true
+		r.addError(key, errTemplateTimeout.Error())
+		return ""
+	case errors.Is(err, errTemplateTooLarge):
+		r.addError(key, errTemplateTooLarge.Error())
+		return ""
+	case err != nil:
+		r.addError(key, err.Error())
+		return ""
+	}
+
+	return strings.TrimSpace(w.sb.String())
+}
+
+func (r *templateRenderer) checkDeadline() (string, error) {
+	if time.Now().After(r.deadline) {
+		return "", errTemplateTimeout
+	}
+	return "", nil
+}
+
+// guardLoops adds the deadline check at the start of the body of the loops
+// of list.
+func guardLoops(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.RangeNode:
+			guardLoops(n.List)
+			guardLoops(n.ElseList)
+			if n.List != nil {
+				n.List.Nodes = slices.Insert(n.List.Nodes, 0, deadlineCheck)
+			}
+		case *parse.IfNode:
+			guardLoops(n.List)
+			guardLoops(n.ElseList)
+		case *parse.WithNode:
+			guardLoops(n.List)
+			guardLoops(n.ElseList)
+		}
+	}
+}
+
+// limitedWriter fails the writes past its size limit or its deadline.
+type limitedWriter struct {
+	sb       strings.Builder
+	limit    int
+	deadline time.Time
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if time.Now().After(w.deadline) {
+		return 0, errTemplateTimeout
+	}
+	if w.sb.Len()+len(p) > w.limit {
+		return 0, errTemplateTooLarge
+	}
+
+	return w.sb.Write(p)
+}
+
+func (r *templateRenderer) addError(key, message string) {
+	if r.errors == nil {
+		r.errors = make(map[string]string)
+	}
+	if _, exists := r.errors[key]; !exists {
+		r.errors[key] = message
+	}
+}
+
+// discordTimestamp formats t as a Discord timestamp, which every reader sees
+// in their own time zone. Style is one of Discord's, R shows a countdown.
+func discordTimestamp(t time.Time, style string) string {
+	return fmt.Sprintf("<t:%d:%s>", t.Unix(), style)
+}
+
+// formatCountdown formats the time left before an occurrence in days, hours
+// and minutes.
+func formatCountdown(d time.Duration) string {
+	if d < time.Minute {
+		return "now"
+	}
+
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := d % (24 * time.Hour) / time.Hour
+	minutes := d % time.Hour / time.Minute
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// previewTemplateHandler renders the message announcing the next occurrence
+// of the event on each of its webhooks, or on the webhook given in the body,
+// for the reminder given in the body if any. A template given in the body is
+// used instead of the saved ones, so it can be tried before it is saved.
+func (app *application) previewTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		EventID    uuid.UUID             `json:"event_id"`
+		WebhookID  *uuid.UUID            `json:"webhook_id"`
+		ReminderID *uuid.UUID            `json:"reminder_id"`
+		Template   *data.MessageTemplate `json:"template"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.EventID != uuid.Nil, "event_id", "must be provided")
+	if input.Template != nil {
+		data.ValidateMessageTemplate(v, input.Template)
+		validateMessageTemplate(v, input.Template)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	event, err := app.models.Events.Get(input.EventID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var reminder *data.Reminder
+	if input.ReminderID != nil {
+		i := slices.IndexFunc(event.Reminders, func(r data.Reminder) bool {
+			return r.ID == *input.ReminderID
+		})
+		if i < 0 {
+			app.notFoundResponse(w, r)
+			return
+		}
+		reminder = &event.Reminders[i]
+	}
+
+	instance, err := nextInstance(event, time.Now())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	webhookIDs := event.Channels()
+	if input.WebhookID != nil {
+		webhookIDs = []uuid.UUID{*input.WebhookID}
+	}
+
+	type preview struct {
+		WebhookID   uuid.UUID `json:"webhook_id"`
+		ChannelType string    `json:"channel_type"`
+		Body        any       `json:"body"`
+	}
+
+	previews := []preview{}
+	for _, webhookID := range webhookIDs {
+		webhook, err := app.models.Webhooks.GetByID(webhookID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		notifier, err := app.notifier(webhook)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		tmpl := input.Template
+		if tmpl == nil {
+			tmpl = messageTemplate(event, webhook, reminder)
+		}
+
+		msg, err := app.message(event, instance, reminder, tmpl)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		previews = append(previews, preview{
+			WebhookID:   webhook.ID,
+			ChannelType: webhook.ChannelType,
+			Body:        notifier.Payload(msg),
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"previews": previews}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
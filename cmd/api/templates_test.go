@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// testTemplateData is an occurrence of a raid starting in a day and a half.
+func testTemplateData() templateData {
+	start := time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)
+
+	event := data.Event{
+		ID:          uuid.New(),
+		Title:       "Raid night",
+		Description: "Bring potions",
+		Duration:    "PT2H",
+		StartDate:   start,
+		Timezone:    "UTC",
+		Tags:        []data.Tag{{Name: "raids"}, {Name: "weekly"}},
+	}
+
+	return newTemplateData(event, &data.EventInstance{
+		EventID:     event.ID,
+		Title:       "Raid night, heroic",
+		Description: event.Description,
+		StartDate:   start,
+		EndDate:     start.Add(2 * time.Hour),
+	}, start.Add(-36*time.Hour))
+}
+
+func TestRenderMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmpl     *data.MessageTemplate
+		reminder *data.Reminder
+		want     Message
+	}{
+		{
+			name: "default",
+			want: Message{
+				Content:     "Raid night",
+				Title:       "Raid night, heroic",
+				Description: "Bring potions",
+				Color:       0xE67E22,
+			},
+		},
+		{
+			name:     "default after the end",
+			reminder: &data.Reminder{RelativeTo: data.ReminderEnd},
+			want: Message{
+				Content:     "Raid night has ended",
+				Title:       "Raid night, heroic",
+				Description: "Bring potions",
+				Color:       0x95A5A6,
+			},
+		},
+		{
+			name: "parts replaced",
+			tmpl: &data.MessageTemplate{
+				Content: "{{upper .Event.Title}} in {{.Countdown}}",
+				Color:   "#3498DB",
+				Footer:  "{{join .Tags \", \"}}",
+				Fields: []data.TemplateField{
+					{Name: "Starts", Value: "{{timestamp .Start \"F\"}}", Inline: true},
+					{Name: "Day", Value: "{{date \"Monday 2 January\" .Start}}"},
+				},
+				Author: &data.TemplateAuthor{Name: "{{truncate 4 .Title}}"},
+			},
+			want: Message{
+				Content:     "RAID NIGHT in 1d 12h",
+				Title:       "Raid night, heroic",
+				Description: "Bring potions",
+				Color:       0x3498DB,
+				Footer:      "raids, weekly",
+				Fields: []MessageField{
+					{Name: "Starts", Value: "<t:1748894400:F>", Inline: true},
+					{Name: "Day", Value: "Monday 2 June"},
+				},
+				Author: MessageAuthor{Name: "Rai…"},
+			},
+		},
+		{
+			name: "trimmed",
+			tmpl: &data.MessageTemplate{
+				Description: "\n  {{- if .Event.RSVP}}RSVP below{{end}}  \n{{.Description}}\n",
+			},
+			want: Message{
+				Content:     "Raid night",
+				Title:       "Raid night, heroic",
+				Description: "Bring potions",
+				Color:       0xE67E22,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testTemplateData()
+			d.Reminder = tt.reminder
+
+			msg, err := renderMessage(tt.tmpl, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.want.EventID = d.Event.ID
+			tt.want.StartDate = d.Start
+			tt.want.EndDate = d.End
+			if fmt.Sprintf("%+v", msg) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("got %+v, want %+v", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMessageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl *data.MessageTemplate
+		key  string
+		want string
+	}{
+		{
+			name: "syntax",
+			tmpl: &data.MessageTemplate{Title: "{{.Title"},
+			key:  "title",
+		},
+		{
+			name: "unknown field",
+			tmpl: &data.MessageTemplate{Content: "{{.Location}}"},
+			key:  "content",
+		},
+		{
+			name: "color",
+			tmpl: &data.MessageTemplate{Color: "orange"},
+			key:  "color",
+			want: "must be a hex color such as #E67E22",
+		},
+		{
+			name: "field",
+			tmpl: &data.MessageTemplate{Fields: []data.TemplateField{{Name: "Who", Value: "{{.Nobody}}"}}},
+			key:  "fields[0].value",
+		},
+		{
+			name: "too large",
+			tmpl: &data.MessageTemplate{Description: "{{range 20000}}ab{{end}}"},
+			key:  "description",
+			want: errTemplateTooLarge.Error(),
+		},
+		{
+			name: "endless loop",
+			tmpl: &data.MessageTemplate{Description: "{{range 2000000000}}{{end}}"},
+			key:  "description",
+			want: errTemplateTimeout.Error(),
+		},
+		{
+			name: "endless recursion",
+			tmpl: &data.MessageTemplate{Footer: `{{define "r"}}{{template "r" .}}{{end}}{{template "r" .}}`},
+			key:  "footer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+
+			r := templateRenderer{data: testTemplateData()}
+			r.message(mergeTemplate(defaultTemplate, tt.tmpl))
+
+			if elapsed := time.Since(start); elapsed > 10*templateTimeout {
+				t.Errorf("got the template rendered in %s, want it stopped at %s", elapsed, templateTimeout)
+			}
+
+			got, ok := r.errors[tt.key]
+			if !ok {
+				t.Fatalf("got errors %v, want one for %s", r.errors, tt.key)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("got error %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMessageTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl *data.MessageTemplate
+		want map[string]string
+	}{
+		{
+			name: "valid",
+			tmpl: &data.MessageTemplate{Content: "{{.Title}} starts {{timestamp .Start \"R\"}}"},
+			want: map[string]string{},
+		},
+		{
+			name: "none",
+			want: map[string]string{},
+		},
+		{
+			name: "too long",
+			tmpl: &data.MessageTemplate{
+				Title:  strings.Repeat("a", 257),
+				Fields: []data.TemplateField{{Name: "Who", Value: "{{range 60}}{{$.Description}} {{end}}"}},
+			},
+			want: map[string]string{
+				"template.title":           "must not render more than 256 characters",
+				"template.fields[0].value": "must not render more than 1024 characters",
+			},
+		},
+		{
+			name: "unknown function",
+			tmpl: &data.MessageTemplate{Content: "{{shout .Title}}"},
+			want: map[string]string{
+				"template.content": `template: content:1: function "shout" not defined`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			validateMessageTemplate(v, tt.tmpl)
+
+			if fmt.Sprint(v.Errors) != fmt.Sprint(tt.want) {
+				t.Errorf("got errors %v, want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Hour, "now"},
+		{30 * time.Second, "now"},
+		{90 * time.Second, "2m"},
+		{time.Hour, "1h"},
+		{25*time.Hour + 5*time.Minute, "1d 1h 5m"},
+		{48 * time.Hour, "2d"},
+	}
+
+	for _, tt := range tests {
+		if got := formatCountdown(tt.d); got != tt.want {
+			t.Errorf("formatCountdown(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestNewMessageOversizedEvent(t *testing.T) {
+	start := time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC)
+
+	event := data.Event{
+		ID:          uuid.New(),
+		Title:       strings.Repeat("Raid night ", 40),
+		Description: strings.Repeat("Bring potions. ", 400),
+		Duration:    "PT1H",
+		StartDate:   start,
+		Timezone:    "UTC",
+	}
+	for i := range 60 {
+		event.Mentions.Roles = append(event.Mentions.Roles, fmt.Sprintf("1%017d", i))
+	}
+
+	tmpl := &data.MessageTemplate{
+		Content: "{{.Description}}",
+		Footer:  "{{.Description}}",
+		Fields: []data.TemplateField{
+			{Name: "{{.Title}}", Value: "{{.Description}}"},
+			{Name: "Who", Value: "{{.Description}}"},
+		},
+	}
+
+	instance, err := newEventInstance(event, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := NewMessage(event, &instance, nil, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := discordNotifier{}.Payload(msg).(DiscordBody)
+	embed := body.Embeds[0]
+
+	length := utf8.RuneCountInString
+	total := length(embed.Title) + length(embed.Description) + length(embed.Footer.Text)
+	for _, field := range embed.Fields {
+		total += length(field.Name) + length(field.Value)
+	}
+
+	checks := []struct {
+		part  string
+		got   int
+		limit int
+	}{
+		{"content", length(body.Content), 2000},
+		{"title", length(embed.Title), 256},
+		{"description", length(embed.Description), 4096},
+		{"footer", length(embed.Footer.Text), 2048},
+		{"field name", length(embed.Fields[0].Name), 256},
+		{"field value", length(embed.Fields[0].Value), 1024},
+		{"embed", total, 6000},
+	}
+
+	for _, c := range checks {
+		if c.got > c.limit {
+			t.Errorf("got a %s of %d characters, want at most %d", c.part, c.got, c.limit)
+		}
+	}
+
+	if !strings.HasPrefix(body.Content, discordMentions(event.Mentions)+" Bring potions.") {
+		t.Errorf("got content %.80q..., want the mentions followed by the description", body.Content)
+	}
+	if !strings.HasSuffix(body.Content, "…") {
+		t.Errorf("got content ending with %q, want it truncated", body.Content[len(body.Content)-10:])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{5, "short", "short"},
+		{4, "short", "sho…"},
+		{1, "short", "…"},
+		{0, "short", ""},
+		{-3, "short", ""},
+		{2, "été", "é…"},
+		{0, "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
@@ -52,18 +52,10 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if isFirstUser {
-		err = app.models.Permissions.AddForUser(user.ID, "admin:read", "admin:write")
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-	} else {
-		err = app.models.Permissions.AddForUser(user.ID, "user:read")
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
+	err = app.grantInitialPermissions(user, isFirstUser)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	err = app.writeJSON(w, http.StatusAccepted, envelope{"user": user}, nil)
@@ -72,6 +64,16 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// grantInitialPermissions gives a new user their permissions, the first user
+// being the admin.
+func (app *application) grantInitialPermissions(user *data.User, isFirstUser bool) error {
+	if isFirstUser {
+		return app.models.Permissions.AddForUser(user.ID, "admin:read", "admin:write")
+	}
+
+	return app.models.Permissions.AddForUser(user.ID, "user:read")
+}
+
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		TokenPlaintext string `json:"token"`
@@ -191,3 +193,64 @@ func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// linkDiscordUserHandler links the user with the email to a Discord account,
+// so the slash commands run by this account act as the user. An empty
+// discord_id unlinks the user.
+func (app *application) linkDiscordUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email     string `json:"email"`
+		DiscordID string `json:"discord_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+	if input.DiscordID != "" {
+		data.ValidateDiscordID(v, input.DiscordID)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("email", "no matching email address found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.DiscordID = input.DiscordID
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateDiscordID):
+			v.AddError("discord_id", "is already linked to another user")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name             string                `json:"name"`
+		ChannelType      string                `json:"channel_type"`
+		URL              string                `json:"url"`
+		Template         *data.MessageTemplate `json:"template"`
+		ApplicationOwned bool                  `json:"application_owned"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.ChannelType == "" {
+		input.ChannelType = data.ChannelDiscord
+	}
+
+	webhook := &data.Webhook{
+		Name:             input.Name,
+		ChannelType:      input.ChannelType,
+		URL:              input.URL,
+		Template:         input.Template,
+		ApplicationOwned: input.ApplicationOwned,
+	}
+
+	if webhook.Template != nil && webhook.Template.IsZero() {
+		webhook.Template = nil
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	validateMessageTemplate(v, webhook.Template)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(webhook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook, err := app.models.Webhooks.GetByID(webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	webhooks, metadata, err := app.models.Webhooks.GetAll(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Name             string                `json:"name"`
+		ChannelType      string                `json:"channel_type"`
+		URL              string                `json:"url"`
+		Template         *data.MessageTemplate `json:"template"`
+		ApplicationOwned *bool                 `json:"application_owned"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook, err := app.models.Webhooks.GetByID(webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if input.Name != "" {
+		webhook.Name = input.Name
+	}
+	if input.ChannelType != "" {
+		webhook.ChannelType = input.ChannelType
+	}
+	// The masked URL the webhook is read with leaves its secret as it is.
+	if input.URL != "" && input.URL != webhook.MaskedURL() {
+		webhook.URL = input.URL
+	}
+	// An empty template goes back to the default one.
+	if input.Template != nil {
+		webhook.Template = input.Template
+		if input.Template.IsZero() {
+			webhook.Template = nil
+		}
+	}
+	if input.ApplicationOwned != nil {
+		webhook.ApplicationOwned = *input.ApplicationOwned
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	validateMessageTemplate(v, webhook.Template)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Update(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Deleting a webhook cascades to the events using it, so only do it
+	// when nothing active depends on it or when the caller insists.
+	force := app.readString(r.URL.Query(), "force", "false") == "true"
+	if !force {
+		count, err := app.models.Webhooks.CountActiveEvents(webhookID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if count > 0 {
+			message := fmt.Sprintf("the webhook is used by %d active event(s), use ?force=true to delete it anyway", count)
+			app.errorResponse(w, r, http.StatusConflict, message)
+			return
+		}
+	}
+
+	err = app.models.Webhooks.Delete(webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// testWebhookHandler sends a test message to the webhook through the regular
+// delivery path and reports how the channel answered. With ?dry_run=true
+// nothing is sent, the handler renders the message the scheduler would send
+// for the event given in ?event_id= instead.
+func (app *application) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook, err := app.models.Webhooks.GetByID(webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	notifier, err := app.notifier(webhook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	qs := r.URL.Query()
+
+	if app.readString(qs, "dry_run", "false") == "true" {
+		eventID, err := uuid.Parse(app.readString(qs, "event_id", ""))
+		if err != nil {
+			v := validator.New()
+			v.AddError("event_id", "must be a valid event ID")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		event, err := app.models.Events.Get(eventID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		instance, err := nextInstance(event, time.Now())
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		msg, err := app.message(event, instance, nil, messageTemplate(event, webhook, nil))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": true, "body": notifier.Payload(msg)}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	start := time.Now()
+
+	msg := Message{
+		Content:     "[TEST] GoEventBot webhook test",
+		Title:       "Test message",
+		Description: "This message was sent to check that the webhook works, it can be safely ignored.",
+		StartDate:   start,
+		EndDate:     start,
+	}
+
+	resp, err := notifier.Send(r.Context(), webhook.URL, notifier.Payload(msg))
+	latency := time.Since(start)
+
+	result := map[string]any{
+		"success":    err == nil,
+		"latency_ms": latency.Milliseconds(),
+	}
+	if resp != nil {
+		result["status_code"] = resp.StatusCode
+		if len(resp.Body) > 0 {
+			result["response_body"] = string(resp.Body)
+		}
+	}
+	if err != nil {
+		result["error"] = redactURLError(err).Error()
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"test": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
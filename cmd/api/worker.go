@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/data"
+	duration "github.com/channelmeter/iso8601duration"
+	"github.com/google/uuid"
+)
+
+type schedulerOptions struct {
+	pollInterval   time.Duration
+	horizon        time.Duration
+	lease          time.Duration
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+}
+
+func (app *application) parseSchedulerOptions() (schedulerOptions, error) {
+	var opts schedulerOptions
+	var err error
+
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"poll interval", app.config.Scheduler.PollInterval, &opts.pollInterval},
+		{"horizon", app.config.Scheduler.Horizon, &opts.horizon},
+		{"lease duration", app.config.Scheduler.LeaseDuration, &opts.lease},
+		{"retry base delay", app.config.Scheduler.RetryBaseDelay, &opts.retryBaseDelay},
+		{"retry max delay", app.config.Scheduler.RetryMaxDelay, &opts.retryMaxDelay},
+	}
+
+	for _, d := range durations {
+		*d.dst, err = time.ParseDuration(d.value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid scheduler %s: %w", d.name, err)
+		}
+	}
+
+	return opts, nil
+}
+
+// startScheduler runs the job scheduler in the background until ctx is
+// cancelled. Every poll interval it materializes the upcoming occurrences of
+// the active events into the jobs table, then executes the jobs that are due.
+// All the state lives in the database, so a restarted process resumes from
+// the pending jobs left by the previous one, and several instances can share
+// the same table: each job is claimed under a lease by exactly one worker.
+func (app *application) startScheduler(ctx context.Context, executor Scheduler) error {
+	opts, err := app.parseSchedulerOptions()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("starting scheduler", "worker_id", app.config.Scheduler.WorkerID)
+
+	app.background(func() {
+		ticker := time.NewTicker(opts.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			app.materializeJobs(opts.horizon)
+			app.runDueJobs(ctx, executor, opts)
+
+			select {
+			case <-ctx.Done():
+				app.logger.Info("stopped scheduler")
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	return nil
+}
+
+func (app *application) materializeJobs(horizon time.Duration) {
+	events, err := app.models.Events.GetActiveEvents()
+	if err != nil {
+		app.logger.Error("Unable to get active events", "error", err)
+		return
+	}
+
+	now := time.Now()
+
+	for _, event := range events {
+		jobs, err := eventJobs(event, now, now.Add(horizon))
+		if err != nil {
+			app.logger.Error("Unable to expand event", "event_id", event.ID, "error", err)
+			continue
+		}
+
+		for _, job := range jobs {
+			job.MaxAttempts = app.config.Scheduler.MaxAttempts
+
+			err := app.models.Jobs.Schedule(job)
+			if err != nil {
+				app.logger.Error("Unable to schedule job", "event_id", event.ID, "webhook_id", job.WebhookID, "error", err)
+			}
+		}
+	}
+}
+
+// eventJobs returns the jobs of the event executing between from and to. An
+// occurrence is announced at each reminder of the event, or at its start
+// when the event has none. Each webhook gets its own job, so a channel
+// failing does not repeat the announcement on the others when it is retried.
+func eventJobs(event data.Event, from, to time.Time) ([]data.Job, error) {
+	reminders := event.Reminders
+	if len(reminders) == 0 {
+		reminders = []data.Reminder{{Offset: "PT0S", RelativeTo: data.ReminderStart}}
+	}
+
+	eventDuration, err := duration.FromString(event.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	// The occurrences are looked up far enough around the interval for the
+	// reminders sent before their start or after their end to fall in it.
+	var before, after time.Duration
+	for _, reminder := range reminders {
+		offset, err := reminder.ParseOffset()
+		if err != nil {
+			return nil, err
+		}
+		if reminder.RelativeTo == data.ReminderEnd {
+			offset += eventDuration.ToDuration()
+		}
+
+		before = max(before, -offset)
+		after = max(after, offset)
+	}
+
+	instances, err := expandEvent(event, from.Add(-after), to.Add(before))
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []data.Job
+
+	for _, instance := range instances {
+		for _, reminder := range reminders {
+			executionDate, err := reminder.FireDate(instance.StartDate, instance.EndDate)
+			if err != nil {
+				return nil, err
+			}
+			if executionDate.Before(from) || !executionDate.Before(to) {
+				continue
+			}
+
+			var reminderID *uuid.UUID
+			if reminder.ID != uuid.Nil {
+				reminderID = &reminder.ID
+			}
+
+			for _, webhookID := range event.Channels() {
+				jobs = append(jobs, data.Job{
+					EventId:        event.ID,
+					WebhookID:      webhookID,
+					ReminderID:     reminderID,
+					OccurrenceDate: instance.StartDate,
+					ExecutionDate:  executionDate,
+				})
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+func (app *application) runDueJobs(ctx context.Context, executor Scheduler, opts schedulerOptions) {
+	for i := 0; i < app.config.Scheduler.BatchSize; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := app.models.Jobs.Claim(app.config.Scheduler.WorkerID, opts.lease)
+		if err != nil {
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				app.logger.Error("Unable to claim job", "error", err)
+			}
+			return
+		}
+
+		app.runJob(ctx, executor, *job, opts)
+	}
+}
+
+// runJob sends the job, which must be done before its lease expires: another
+// worker would claim it again and send it twice otherwise.
+func (app *application) runJob(ctx context.Context, executor Scheduler, job data.Job, opts schedulerOptions) {
+	event, err := app.models.Events.Get(job.EventId)
+	if err != nil {
+		app.failJob(job, err, opts)
+		return
+	}
+
+	reminder := jobReminder(event, job)
+
+	if !event.IsActive || !slices.Contains(event.Channels(), job.WebhookID) || (job.ReminderID != nil && reminder == nil) {
+		// The event was disabled, or the webhook or the reminder removed
+		// from it, after the job was materialized.
+		if err := app.models.Jobs.Delete(job.ID); err != nil {
+			app.logger.Error("Unable to delete job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	webhook, err := app.models.Webhooks.GetByID(job.WebhookID)
+	if err != nil {
+		app.failJob(job, err, opts)
+		return
+	}
+
+	// Half of the lease is left to record the outcome of the send.
+	sendCtx, cancel := context.WithTimeout(ctx, opts.lease/2)
+	defer cancel()
+
+	messageID, err := executor.Execute(sendCtx, event, webhook, reminder, job.OccurrenceDate)
+	if err != nil {
+		app.failJob(job, err, opts)
+		return
+	}
+
+	err = app.models.Jobs.Complete(job.ID, job.LockedBy, messageID)
+	if err != nil {
+		app.logJobUpdateError(job, data.Completed, err)
+	}
+}
+
+// jobReminder returns the reminder of event the job sends, nil when the job
+// has no reminder or when it was removed from the event.
+func jobReminder(event data.Event, job data.Job) *data.Reminder {
+	if job.ReminderID == nil {
+		return nil
+	}
+
+	i := slices.IndexFunc(event.Reminders, func(r data.Reminder) bool {
+		return r.ID == *job.ReminderID
+	})
+	if i < 0 {
+		return nil
+	}
+
+	return &event.Reminders[i]
+}
+
+// failJob schedules another attempt of a failed job, or dead-letters it once
+// all its attempts are used.
+func (app *application) failJob(job data.Job, jobErr error, opts schedulerOptions) {
+	app.logger.Error("Job failed", "job_id", job.ID, "event_id", job.EventId, "attempt", job.Attempts, "error", jobErr)
+
+	if job.Attempts >= job.MaxAttempts {
+		app.logger.Warn("Job dead-lettered", "job_id", job.ID, "attempts", job.Attempts)
+		app.finishJob(job, data.DeadLettered, jobErr.Error())
+		return
+	}
+
+	nextAttempt := time.Now().Add(retryDelay(job.Attempts, opts.retryBaseDelay, opts.retryMaxDelay))
+
+	err := app.models.Jobs.Retry(job.ID, job.LockedBy, jobErr.Error(), nextAttempt)
+	if err != nil {
+		app.logJobUpdateError(job, data.Failed, err)
+	}
+}
+
+func (app *application) finishJob(job data.Job, status data.JobStatus, lastError string) {
+	err := app.models.Jobs.Finish(job.ID, job.LockedBy, status, lastError)
+	if err != nil {
+		app.logJobUpdateError(job, status, err)
+	}
+}
+
+func (app *application) logJobUpdateError(job data.Job, status data.JobStatus, err error) {
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.logger.Warn("Job lease lost before completion", "job_id", job.ID, "status", status.String())
+	default:
+		app.logger.Error("Unable to update job status", "job_id", job.ID, "status", status.String(), "error", err)
+	}
+}
+
+// retryDelay returns the exponential backoff before the attempt following the
+// given one, capped to maxDelay. Half of the delay is randomized so that jobs
+// which failed together do not all retry at the same instant.
+func retryDelay(attempt int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + rand.N(half)
+}
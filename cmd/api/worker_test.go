@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempt  int
+		base     time.Duration
+		maxDelay time.Duration
+		want     time.Duration
+	}{
+		{"first attempt", 1, time.Second, time.Hour, time.Second},
+		{"no attempt yet", 0, time.Second, time.Hour, time.Second},
+		{"second attempt", 2, time.Second, time.Hour, 2 * time.Second},
+		{"fifth attempt", 5, time.Second, time.Hour, 16 * time.Second},
+		{"capped", 10, time.Second, time.Minute, time.Minute},
+		{"many attempts", 1000, time.Second, time.Minute, time.Minute},
+		{"base above the cap", 1, time.Hour, time.Minute, time.Minute},
+		{"no base", 3, 0, time.Minute, 0},
+		{"one nanosecond", 1, time.Nanosecond, time.Minute, time.Nanosecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Half of the delay is random, the bounds are checked a few
+			// times.
+			for range 100 {
+				got := retryDelay(tt.attempt, tt.base, tt.maxDelay)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("got %s, want between %s and %s", got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
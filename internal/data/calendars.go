@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// CalendarSyncModel stores, for each calendar source, the cursor from which
+// its next synchronization resumes.
+type CalendarSyncModel struct {
+	DB *sql.DB
+}
+
+// GetCursor returns the cursor of the source, empty when it was never
+// synchronized.
+func (m CalendarSyncModel) GetCursor(source string) (string, error) {
+	query := `SELECT sync_cursor FROM calendar_syncs WHERE source = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var cursor string
+	err := m.DB.QueryRowContext(ctx, query, source).Scan(&cursor)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", nil
+		default:
+			return "", err
+		}
+	}
+
+	return cursor, nil
+}
+
+func (m CalendarSyncModel) SetCursor(source, cursor string) error {
+	query := `
+		INSERT INTO calendar_syncs (source, sync_cursor) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET sync_cursor = EXCLUDED.sync_cursor, updated_date = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, source, cursor)
+	return err
+}
@@ -4,29 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"slices"
+	"strings"
 	"time"
 )
 
 type Event struct {
-	ID          uuid.UUID `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Duration    string    `json:"duration"`
-	RRule       string    `json:"rrule,omitempty"`
-	IsActive    bool      `json:"is_active"`
-	CreatedDate time.Time `json:"created_date"`
-	UpdatedDate time.Time `json:"updated_date"`
+	ID              uuid.UUID            `json:"id"`
+	Title           string               `json:"title"`
+	Description     string               `json:"description"`
+	Duration        string               `json:"duration"`
+	RRule           string               `json:"rrule,omitempty"`
+	StartDate       time.Time            `json:"start_date"`
+	Timezone        string               `json:"timezone"`
+	IsActive        bool                 `json:"is_active"`
+	WebhookID       uuid.UUID            `json:"webhook_id"`
+	WebhookIDs      []uuid.UUID          `json:"webhook_ids"`
+	Template        *MessageTemplate     `json:"template,omitempty"`
+	Mentions        Mentions             `json:"mentions"`
+	RSVP            bool                 `json:"rsvp"`
+	Capacity        int                  `json:"capacity"`
+	ICalUID         string               `json:"ical_uid,omitempty"`
+	Source          string               `json:"source,omitempty"`
+	ExternalID      string               `json:"external_id,omitempty"`
+	SourceCancelled bool                 `json:"source_cancelled,omitempty"`
+	Tags            []Tag                `json:"tags"`
+	ExDates         []time.Time          `json:"exdates"`
+	RDates          []time.Time          `json:"rdates"`
+	Overrides       []OccurrenceOverride `json:"overrides"`
+	Reminders       []Reminder           `json:"reminders"`
+	CreatedDate     time.Time            `json:"created_date"`
+	UpdatedDate     time.Time            `json:"updated_date"`
 }
 
 type EventInstance struct {
-	EventID     uuid.UUID `json:"event_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Duration    string    `json:"duration"`
-	StartDate   time.Time `json:"start_date"`
-	EndDate     time.Time `json:"end_date"`
+	EventID       uuid.UUID `json:"event_id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Duration      string    `json:"duration"`
+	Tags          []Tag     `json:"tags,omitempty"`
+	OriginalStart time.Time `json:"original_start"`
+	StartDate     time.Time `json:"start_date"`
+	EndDate       time.Time `json:"end_date"`
+}
+
+// AllMentions returns the mentions of the event along with its tags'.
+func (e Event) AllMentions() Mentions {
+	mentions := e.Mentions
+	for _, tag := range e.Tags {
+		mentions = mentions.Merge(tag.Mentions)
+	}
+
+	return mentions
+}
+
+// Channels returns the webhooks the event is announced on, its own webhook
+// first.
+func (e Event) Channels() []uuid.UUID {
+	channels := []uuid.UUID{e.WebhookID}
+	for _, id := range e.WebhookIDs {
+		if !slices.Contains(channels, id) {
+			channels = append(channels, id)
+		}
+	}
+
+	return channels
 }
 
 func ValidateEvent(v *validator.Validator, event *Event) {
@@ -36,6 +82,25 @@ func ValidateEvent(v *validator.Validator, event *Event) {
 	v.IsValidDurationRule(event.Duration)
 
 	v.IsValidRRule(event.RRule)
+	v.Check(!strings.Contains(strings.ToUpper(event.RRule), "DTSTART"), "rrule", "must not contain DTSTART, use start_date instead")
+	v.Check(!event.StartDate.IsZero(), "start_date", "must be provided")
+	v.Check(event.Timezone != "", "timezone", "must be provided")
+	_, err := time.LoadLocation(event.Timezone)
+	v.Check(err == nil, "timezone", "must be a valid IANA time zone name")
+	v.Check(event.WebhookID != uuid.Nil, "webhook_id", "must be provided")
+
+	if event.Template != nil {
+		ValidateMessageTemplate(v, event.Template)
+	}
+	ValidateMentions(v, event.Mentions)
+	v.Check(event.Capacity >= 0, "capacity", "must not be negative")
+
+	for _, date := range event.ExDates {
+		v.Check(!date.IsZero(), "exdates", "must only contain valid dates")
+	}
+	for _, date := range event.RDates {
+		v.Check(!date.IsZero(), "rdates", "must only contain valid dates")
+	}
 }
 
 type EventModel struct {
@@ -43,14 +108,18 @@ type EventModel struct {
 }
 
 func (e EventModel) Insert(event *Event) error {
-	query := `INSERT INTO events (title, description, duration, rrule, is_active) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_date, updated_date`
-
-	args := []any{event.Title, event.Description, event.Duration, event.RRule, event.IsActive}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := e.DB.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedDate, &event.UpdatedDate)
+	return insertEvent(ctx, e.DB, event)
+}
+
+func insertEvent(ctx context.Context, db execer, event *Event) error {
+	query := `INSERT INTO events (title, description, duration, rrule, start_date, timezone, is_active, webhook_id, ical_uid, source, external_id, message_template, mentions, rsvp, capacity, source_cancelled) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12, $13, $14, $15, $16) RETURNING id, created_date, updated_date`
+
+	args := []any{event.Title, event.Description, event.Duration, event.RRule, event.StartDate, event.Timezone, event.IsActive, event.WebhookID, event.ICalUID, event.Source, event.ExternalID, event.Template, event.Mentions, event.RSVP, event.Capacity, event.SourceCancelled}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedDate, &event.UpdatedDate)
 
 	if err != nil {
 		return err
@@ -59,19 +128,56 @@ func (e EventModel) Insert(event *Event) error {
 }
 
 func (e EventModel) Get(ID uuid.UUID) (Event, error) {
-	query := `SELECT id, title, description, duration, rrule, is_active, created_date, updated_date FROM events WHERE id = $1`
+	return e.getBy("id = $1", ID)
+}
+
+// GetByICalUID returns the event imported from the iCalendar event with the
+// given UID.
+func (e EventModel) GetByICalUID(uid string) (Event, error) {
+	event, err := e.getBy("ical_uid = $1", uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Event{}, ErrRecordNotFound
+	}
+
+	return event, err
+}
+
+// GetByExternalID returns the event synchronized from the calendar source
+// under the source's own ID for it.
+func (e EventModel) GetByExternalID(source, externalID string) (Event, error) {
+	event, err := e.getBy("source = $1 AND external_id = $2", source, externalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Event{}, ErrRecordNotFound
+	}
+
+	return event, err
+}
+
+func (e EventModel) getBy(where string, args ...any) (Event, error) {
+	query := `SELECT id, title, description, duration, rrule, start_date, timezone, is_active, webhook_id, COALESCE(ical_uid, ''), COALESCE(source, ''), COALESCE(external_id, ''), message_template, mentions, rsvp, capacity, source_cancelled, created_date, updated_date FROM events WHERE ` + where
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var event Event
 
-	err := e.DB.QueryRowContext(ctx, query, ID).Scan(
+	err := e.DB.QueryRowContext(ctx, query, args...).Scan(
 		&event.ID,
 		&event.Title,
 		&event.Description,
 		&event.Duration,
 		&event.RRule,
+		&event.StartDate,
+		&event.Timezone,
 		&event.IsActive,
+		&event.WebhookID,
+		&event.ICalUID,
+		&event.Source,
+		&event.ExternalID,
+		templateScanner{&event.Template},
+		&event.Mentions,
+		&event.RSVP,
+		&event.Capacity,
+		&event.SourceCancelled,
 		&event.CreatedDate,
 		&event.UpdatedDate,
 	)
@@ -83,59 +189,110 @@ func (e EventModel) Get(ID uuid.UUID) (Event, error) {
 			return Event{}, err
 		}
 	}
-	return event, nil
+
+	events := []Event{event}
+	if err := e.attachRelations(events); err != nil {
+		return Event{}, err
+	}
+
+	return events[0], nil
 }
 
-func (e EventModel) GetAll() ([]Event, error) {
-	var events []Event
-	query := `SELECT id, title, description, duration, rrule, is_active, created_date, updated_date FROM events`
+// GetAll returns a page of events matching the full-text search on their
+// title and description, restricted to the ones carrying every tag named in
+// tags when it is not empty.
+func (e EventModel) GetAll(search string, tags []string, filters Filters) ([]Event, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, title, description, duration, rrule, start_date, timezone, is_active, webhook_id, COALESCE(ical_uid, ''), COALESCE(source, ''), COALESCE(external_id, ''), message_template, mentions, rsvp, capacity, source_cancelled, created_date, updated_date
+		FROM events
+		WHERE (to_tsvector('simple', title || ' ' || description) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (cardinality($2::text[]) = 0 OR id IN (
+			SELECT event_tags.event_id
+			FROM event_tags
+			INNER JOIN tags ON tags.id = event_tags.tag_id
+			WHERE LOWER(tags.name) = ANY($2)
+			GROUP BY event_tags.event_id
+			HAVING COUNT(DISTINCT LOWER(tags.name)) = cardinality($2::text[])
+		))
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !slices.Contains(names, tag) {
+			names = append(names, tag)
+		}
+	}
+
+	args := []any{search, pq.Array(names), filters.limit(), filters.offset()}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	rows, err := e.DB.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
 
-	defer func(rows *sql.Rows) {
-		err = rows.Close()
-	}(rows)
+	rows, err := e.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []Event{}
 
 	for rows.Next() {
 		var event Event
 		err := rows.Scan(
+			&totalRecords,
 			&event.ID,
 			&event.Title,
 			&event.Description,
 			&event.Duration,
 			&event.RRule,
+			&event.StartDate,
+			&event.Timezone,
 			&event.IsActive,
+			&event.WebhookID,
+			&event.ICalUID,
+			&event.Source,
+			&event.ExternalID,
+			templateScanner{&event.Template},
+			&event.Mentions,
+			&event.RSVP,
+			&event.Capacity,
+			&event.SourceCancelled,
 			&event.CreatedDate,
 			&event.UpdatedDate,
 		)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 		events = append(events, event)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
-	return events, nil
-}
+	if err = e.attachRelations(events); err != nil {
+		return nil, Metadata{}, err
+	}
 
-func (e EventModel) Update(event *Event) error {
-	query := `UPDATE events SET title = $1, description = $2, is_active = $3, duration = $4, rrule = $5, updated_date = NOW() WHERE id = $6 RETURNING updated_date`
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 
-	args := []any{event.Title, event.Description, event.IsActive, event.Duration, event.RRule, event.ID}
+	return events, metadata, nil
+}
 
+func (e EventModel) Update(event *Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := e.DB.QueryRowContext(ctx, query, args...).Scan(&event.UpdatedDate)
+	return updateEvent(ctx, e.DB, event)
+}
+
+func updateEvent(ctx context.Context, db execer, event *Event) error {
+	query := `UPDATE events SET title = $1, description = $2, is_active = $3, duration = $4, rrule = $5, start_date = $6, timezone = $7, webhook_id = $8, ical_uid = NULLIF($9, ''), message_template = $10, mentions = $11, rsvp = $12, capacity = $13, source_cancelled = $14, updated_date = NOW() WHERE id = $15 RETURNING updated_date`
+
+	args := []any{event.Title, event.Description, event.IsActive, event.Duration, event.RRule, event.StartDate, event.Timezone, event.WebhookID, event.ICalUID, event.Template, event.Mentions, event.RSVP, event.Capacity, event.SourceCancelled, event.ID}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&event.UpdatedDate)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -147,6 +304,46 @@ func (e EventModel) Update(event *Event) error {
 	return nil
 }
 
+// Import saves the events in a single transaction, so that either all of
+// them or none are. The events without an ID are inserted, the others
+// updated, along with their exception dates and overrides.
+func (e EventModel) Import(events []*Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		if event.ID == uuid.Nil {
+			err = insertEvent(ctx, tx, event)
+		} else {
+			err = updateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = setEventDates(ctx, tx, event.ID, event.ExDates, event.RDates)
+		if err != nil {
+			return err
+		}
+
+		for i := range event.Overrides {
+			event.Overrides[i].EventID = event.ID
+			err = upsertOverride(ctx, tx, &event.Overrides[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (e EventModel) Delete(ID uuid.UUID) error {
 	query := `DELETE FROM events WHERE id = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -165,7 +362,7 @@ func (e EventModel) Delete(ID uuid.UUID) error {
 }
 
 func (e EventModel) GetActiveEvents() ([]Event, error) {
-	query := `SELECT id, title, description, duration, rrule FROM events WHERE is_active = true`
+	query := `SELECT id, title, description, duration, rrule, start_date, timezone, is_active, webhook_id, COALESCE(ical_uid, ''), COALESCE(source, ''), COALESCE(external_id, ''), message_template, mentions, rsvp, capacity, source_cancelled, created_date, updated_date FROM events WHERE is_active = true`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -192,6 +389,20 @@ func (e EventModel) GetActiveEvents() ([]Event, error) {
 			&event.Description,
 			&event.Duration,
 			&event.RRule,
+			&event.StartDate,
+			&event.Timezone,
+			&event.IsActive,
+			&event.WebhookID,
+			&event.ICalUID,
+			&event.Source,
+			&event.ExternalID,
+			templateScanner{&event.Template},
+			&event.Mentions,
+			&event.RSVP,
+			&event.Capacity,
+			&event.SourceCancelled,
+			&event.CreatedDate,
+			&event.UpdatedDate,
 		)
 		if err != nil {
 			return nil, err
@@ -201,5 +412,143 @@ func (e EventModel) GetActiveEvents() ([]Event, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+
+	if err = e.attachRelations(events); err != nil {
+		return nil, err
+	}
+
 	return events, nil
 }
+
+// attachRelations loads the tags, the additional webhooks, the reminders, the
+// exception dates and the overrides of the events.
+func (e EventModel) attachRelations(events []Event) error {
+	if err := e.attachTags(events); err != nil {
+		return err
+	}
+
+	if err := e.attachWebhooks(events); err != nil {
+		return err
+	}
+
+	if err := e.attachReminders(events); err != nil {
+		return err
+	}
+
+	return e.attachExceptions(events)
+}
+
+func (e EventModel) attachTags(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	tags, err := TagModel{DB: e.DB}.GetAllForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].Tags = tags[events[i].ID]
+		if events[i].Tags == nil {
+			events[i].Tags = []Tag{}
+		}
+	}
+
+	return nil
+}
+
+func (e EventModel) attachWebhooks(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	webhooks, err := WebhookModel{DB: e.DB}.GetIDsForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].WebhookIDs = webhooks[events[i].ID]
+		if events[i].WebhookIDs == nil {
+			events[i].WebhookIDs = []uuid.UUID{}
+		}
+	}
+
+	return nil
+}
+
+func (e EventModel) attachReminders(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	reminders, err := ReminderModel{DB: e.DB}.GetAllForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].Reminders = reminders[events[i].ID]
+		if events[i].Reminders == nil {
+			events[i].Reminders = []Reminder{}
+		}
+	}
+
+	return nil
+}
+
+func (e EventModel) attachExceptions(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	occurrences := OccurrenceModel{DB: e.DB}
+
+	exdates, rdates, err := occurrences.GetDatesForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := occurrences.GetOverridesForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		events[i].ExDates = exdates[events[i].ID]
+		if events[i].ExDates == nil {
+			events[i].ExDates = []time.Time{}
+		}
+		events[i].RDates = rdates[events[i].ID]
+		if events[i].RDates == nil {
+			events[i].RDates = []time.Time{}
+		}
+		events[i].Overrides = overrides[events[i].ID]
+		if events[i].Overrides == nil {
+			events[i].Overrides = []OccurrenceOverride{}
+		}
+	}
+
+	return nil
+}
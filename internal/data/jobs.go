@@ -4,25 +4,39 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
 
 type Job struct {
-	ID            uuid.UUID `json:"id"`
-	EventId       uuid.UUID `json:"event_id"`
-	ExecutionDate time.Time `json:"execution_date"`
-	Status        int       `json:"status"`
+	ID             uuid.UUID  `json:"id"`
+	EventId        uuid.UUID  `json:"event_id"`
+	WebhookID      uuid.UUID  `json:"webhook_id"`
+	ReminderID     *uuid.UUID `json:"reminder_id,omitempty"`
+	OccurrenceDate time.Time  `json:"occurrence_date"`
+	ExecutionDate  time.Time  `json:"execution_date"`
+	Status         JobStatus  `json:"status"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"max_attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	LockedBy       string     `json:"locked_by,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	MessageID      string     `json:"message_id,omitempty"`
+	CreatedDate    time.Time  `json:"created_date"`
+	UpdatedDate    time.Time  `json:"updated_date"`
 }
 
 type JobStatus int
 
 const (
-	Unknown = iota
+	Unknown JobStatus = iota
 	Pending
 	Running
 	Completed
 	Failed
+	DeadLettered
 )
 
 type JobModel struct {
@@ -30,21 +44,51 @@ type JobModel struct {
 }
 
 func (j JobModel) Insert(job *Job) error {
-	query := `INSERT INTO jobs (id, event_id, execution_date, status) VALUES ($1, $2, $3, $4) RETURNING id`
-	args := []any{job.ID, job.EventId, job.ExecutionDate, job.Status}
+	query := `INSERT INTO jobs (id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, max_attempts) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_date, updated_date`
+	args := []any{job.ID, job.EventId, job.WebhookID, job.ReminderID, job.OccurrenceDate, job.ExecutionDate, job.Status, job.MaxAttempts}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := j.DB.QueryRowContext(ctx, query, args...).Scan(&job.ID)
+	err := j.DB.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.CreatedDate, &job.UpdatedDate)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Schedule creates the job as pending. It is a no-op when a job already
+// exists for the same occurrence, webhook and reminder, so it can be called
+// repeatedly for the same window.
+func (j JobModel) Schedule(job Job) error {
+	query := `
+		INSERT INTO jobs (event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, max_attempts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_id, webhook_id, occurrence_date, COALESCE(reminder_id, '00000000-0000-0000-0000-000000000000')) DO NOTHING`
+
+	args := []any{
+		job.EventId,
+		job.WebhookID,
+		job.ReminderID,
+		job.OccurrenceDate.UTC().Truncate(time.Second),
+		job.ExecutionDate.UTC().Truncate(time.Second),
+		Pending,
+		job.MaxAttempts,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
 func (j JobModel) Get(ID uuid.UUID) (Job, error) {
-	query := `SELECT id, event_id, execution_date, status FROM jobs WHERE id = $1`
+	query := `
+		SELECT id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date
+		FROM jobs
+		WHERE id = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -53,8 +97,20 @@ func (j JobModel) Get(ID uuid.UUID) (Job, error) {
 	err := j.DB.QueryRowContext(ctx, query, ID).Scan(
 		&job.ID,
 		&job.EventId,
+		&job.WebhookID,
+		&job.ReminderID,
+		&job.OccurrenceDate,
 		&job.ExecutionDate,
 		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.NextAttemptAt,
+		&job.LastError,
+		&job.LockedBy,
+		&job.LeaseExpiresAt,
+		&job.MessageID,
+		&job.CreatedDate,
+		&job.UpdatedDate,
 	)
 
 	if err != nil {
@@ -67,7 +123,12 @@ func (j JobModel) Get(ID uuid.UUID) (Job, error) {
 }
 
 func (j JobModel) GetByEventID(eventID uuid.UUID) ([]Job, error) {
-	query := `SELECT id, event_id, execution_date, status FROM jobs WHERE event_id = $1`
+	query := `
+		SELECT id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date
+		FROM jobs
+		WHERE event_id = $1
+		ORDER BY execution_date`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -77,24 +138,279 @@ func (j JobModel) GetByEventID(eventID uuid.UUID) ([]Job, error) {
 	}
 	defer rows.Close()
 
-	var jobs []Job
+	return scanJobs(rows)
+}
+
+func (j JobModel) GetAllByStatus(status JobStatus, filters Filters) ([]Job, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date
+		FROM jobs
+		WHERE status = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := j.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	jobs := []Job{}
 	for rows.Next() {
 		var job Job
-		if err := rows.Scan(&job.ID, &job.EventId, &job.ExecutionDate, &job.Status); err != nil {
-			return nil, err
+		err := rows.Scan(
+			&totalRecords,
+			&job.ID,
+			&job.EventId,
+			&job.WebhookID,
+			&job.ReminderID,
+			&job.OccurrenceDate,
+			&job.ExecutionDate,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.NextAttemptAt,
+			&job.LastError,
+			&job.LockedBy,
+			&job.LeaseExpiresAt,
+			&job.MessageID,
+			&job.CreatedDate,
+			&job.UpdatedDate,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
 		}
 		jobs = append(jobs, job)
 	}
 
 	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return jobs, metadata, nil
+}
+
+// Claim locks the oldest due job for the given worker and returns it as
+// running, counting a new attempt. A job is due when it is pending and its
+// execution date has passed, when a failed attempt reached its retry time, or
+// when it is running under a lease that expired because the worker holding it
+// went away. Rows locked by a concurrent claim are skipped, so each job is
+// handed to exactly one worker. ErrRecordNotFound is returned when nothing is
+// due.
+func (j JobModel) Claim(workerID string, lease time.Duration) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = $1, locked_by = $2, lease_expires_at = NOW() + make_interval(secs => $3),
+		attempts = attempts + 1, updated_date = NOW()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE (status IN ($4, $5) AND COALESCE(next_attempt_at, execution_date) <= NOW())
+			OR (status = $1 AND lease_expires_at < NOW())
+			ORDER BY execution_date
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date`
+
+	args := []any{Running, workerID, lease.Seconds(), Pending, Failed}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var job Job
+
+	err := j.DB.QueryRowContext(ctx, query, args...).Scan(
+		&job.ID,
+		&job.EventId,
+		&job.WebhookID,
+		&job.ReminderID,
+		&job.OccurrenceDate,
+		&job.ExecutionDate,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.NextAttemptAt,
+		&job.LastError,
+		&job.LockedBy,
+		&job.LeaseExpiresAt,
+		&job.MessageID,
+		&job.CreatedDate,
+		&job.UpdatedDate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+// Finish records the final outcome of a job claimed by the given worker and
+// releases its lease. ErrEditConflict is returned when the worker no longer
+// holds the job, which happens when its lease expired and another worker
+// reclaimed it.
+func (j JobModel) Finish(ID uuid.UUID, workerID string, status JobStatus, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = NULLIF($2, ''), next_attempt_at = NULL, lease_expires_at = NULL, updated_date = NOW()
+		WHERE id = $3 AND locked_by = $4 AND status = $5`
+
+	args := []any{status, lastError, ID, workerID, Running}
+
+	return j.updateClaimed(query, args...)
+}
+
+// Complete marks a job claimed by the given worker as completed, keeping the
+// ID of the message it sent when the channel returned one.
+func (j JobModel) Complete(ID uuid.UUID, workerID string, messageID string) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = NULL, message_id = NULLIF($2, ''), next_attempt_at = NULL, lease_expires_at = NULL, updated_date = NOW()
+		WHERE id = $3 AND locked_by = $4 AND status = $5`
+
+	args := []any{Completed, messageID, ID, workerID, Running}
+
+	return j.updateClaimed(query, args...)
+}
+
+// Retry marks a job claimed by the given worker as failed and releases its
+// lease, the job becomes due again at nextAttempt.
+func (j JobModel) Retry(ID uuid.UUID, workerID string, lastError string, nextAttempt time.Time) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, last_error = NULLIF($2, ''), next_attempt_at = $3, lease_expires_at = NULL, updated_date = NOW()
+		WHERE id = $4 AND locked_by = $5 AND status = $6`
+
+	args := []any{Failed, lastError, nextAttempt, ID, workerID, Running}
+
+	return j.updateClaimed(query, args...)
+}
+
+func (j JobModel) updateClaimed(query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := j.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// Replay puts a dead-lettered job back in the queue with a fresh set of
+// attempts. ErrRecordNotFound is returned when no dead-lettered job has the
+// given ID.
+func (j JobModel) Replay(ID uuid.UUID) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = 0, next_attempt_at = NOW(), locked_by = NULL, lease_expires_at = NULL, updated_date = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var job Job
+
+	err := j.DB.QueryRowContext(ctx, query, Pending, ID, DeadLettered).Scan(
+		&job.ID,
+		&job.EventId,
+		&job.WebhookID,
+		&job.ReminderID,
+		&job.OccurrenceDate,
+		&job.ExecutionDate,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.NextAttemptAt,
+		&job.LastError,
+		&job.LockedBy,
+		&job.LeaseExpiresAt,
+		&job.MessageID,
+		&job.CreatedDate,
+		&job.UpdatedDate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+// DeletePendingForEvent removes the jobs of an event that have not run yet or
+// are waiting for a retry, so they get materialized again from the current
+// event definition.
+func (j JobModel) DeletePendingForEvent(eventID uuid.UUID) error {
+	query := `DELETE FROM jobs WHERE event_id = $1 AND status IN ($2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, eventID, Pending, Failed)
+	return err
+}
+
+// GetAnnouncedForEvent returns the jobs of an event which sent a message that
+// can still be edited, for the occurrences starting at since or later.
+func (j JobModel) GetAnnouncedForEvent(eventID uuid.UUID, since time.Time) ([]Job, error) {
+	query := `
+		SELECT id, event_id, webhook_id, reminder_id, occurrence_date, execution_date, status, attempts, max_attempts, next_attempt_at,
+		COALESCE(last_error, ''), COALESCE(locked_by, ''), lease_expires_at, COALESCE(message_id, ''), created_date, updated_date
+		FROM jobs
+		WHERE event_id = $1 AND status = $2 AND message_id IS NOT NULL AND occurrence_date >= $3
+		ORDER BY execution_date`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := j.DB.QueryContext(ctx, query, eventID, Completed, since)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return jobs, nil
+	return scanJobs(rows)
+}
+
+// ClearMessageID forgets the message sent by a job, once it was deleted.
+func (j JobModel) ClearMessageID(ID uuid.UUID) error {
+	query := `UPDATE jobs SET message_id = NULL, updated_date = NOW() WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := j.DB.ExecContext(ctx, query, ID)
+	return err
 }
 
 func (j JobModel) Update(job *Job) error {
-	query := `UPDATE jobs SET event_id = $1, execution_date = $2, status = $3 WHERE id = $4`
+	query := `UPDATE jobs SET event_id = $1, execution_date = $2, status = $3, updated_date = NOW() WHERE id = $4`
 	args := []any{job.EventId, job.ExecutionDate, job.Status, job.ID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -119,6 +435,41 @@ func (j JobModel) Delete(ID uuid.UUID) error {
 	return nil
 }
 
+func scanJobs(rows *sql.Rows) ([]Job, error) {
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		err := rows.Scan(
+			&job.ID,
+			&job.EventId,
+			&job.WebhookID,
+			&job.ReminderID,
+			&job.OccurrenceDate,
+			&job.ExecutionDate,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.NextAttemptAt,
+			&job.LastError,
+			&job.LockedBy,
+			&job.LeaseExpiresAt,
+			&job.MessageID,
+			&job.CreatedDate,
+			&job.UpdatedDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
 func (j JobStatus) String() string {
-	return [...]string{"Unknown", "Pending", "Running", "Completed", "Failed"}[j]
+	return [...]string{"Unknown", "Pending", "Running", "Completed", "Failed", "DeadLettered"}[j]
 }
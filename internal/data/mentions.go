@@ -0,0 +1,79 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+)
+
+var snowflakeRX = regexp.MustCompile(`^[0-9]{17,20}$`)
+
+// Mentions are who an announcement pings. Roles and Users are Discord IDs.
+// Nobody else is pinged, whatever the announcement's text contains.
+type Mentions struct {
+	Roles    []string `json:"roles,omitempty"`
+	Users    []string `json:"users,omitempty"`
+	Here     bool     `json:"here,omitempty"`
+	Everyone bool     `json:"everyone,omitempty"`
+}
+
+// IsZero reports whether nobody is mentioned.
+func (m Mentions) IsZero() bool {
+	return len(m.Roles) == 0 && len(m.Users) == 0 && !m.Here && !m.Everyone
+}
+
+// Merge returns the mentions of both m and other.
+func (m Mentions) Merge(other Mentions) Mentions {
+	merged := Mentions{
+		Roles:    slices.Clone(m.Roles),
+		Users:    slices.Clone(m.Users),
+		Here:     m.Here || other.Here,
+		Everyone: m.Everyone || other.Everyone,
+	}
+
+	for _, id := range other.Roles {
+		if !slices.Contains(merged.Roles, id) {
+			merged.Roles = append(merged.Roles, id)
+		}
+	}
+	for _, id := range other.Users {
+		if !slices.Contains(merged.Users, id) {
+			merged.Users = append(merged.Users, id)
+		}
+	}
+
+	return merged
+}
+
+// ValidateMentions checks the IDs, at most 100 roles and 100 users can be
+// allowed in a Discord message.
+func ValidateMentions(v *validator.Validator, m Mentions) {
+	v.Check(len(m.Roles) <= 100, "mentions.roles", "must not contain more than 100 roles")
+	for i, id := range m.Roles {
+		v.Check(validator.Matches(id, snowflakeRX), fmt.Sprintf("mentions.roles[%d]", i), "must be a Discord role ID")
+	}
+
+	v.Check(len(m.Users) <= 100, "mentions.users", "must not contain more than 100 users")
+	for i, id := range m.Users {
+		v.Check(validator.Matches(id, snowflakeRX), fmt.Sprintf("mentions.users[%d]", i), "must be a Discord user ID")
+	}
+}
+
+func (m Mentions) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *Mentions) Scan(src any) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("mentions must be scanned from bytes")
+	}
+
+	*m = Mentions{}
+	return json.Unmarshal(b, m)
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+)
+
+func TestEventAllMentions(t *testing.T) {
+	event := Event{
+		Mentions: Mentions{Roles: []string{"111111111111111111"}, Users: []string{"333333333333333333"}},
+		Tags: []Tag{
+			{Name: "raids", Mentions: Mentions{Roles: []string{"111111111111111111", "222222222222222222"}}},
+			{Name: "weekly", Mentions: Mentions{Here: true, Users: []string{"444444444444444444"}}},
+			{Name: "quiet"},
+		},
+	}
+
+	got := event.AllMentions()
+
+	want := Mentions{
+		Roles: []string{"111111111111111111", "222222222222222222"},
+		Users: []string{"333333333333333333", "444444444444444444"},
+		Here:  true,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if len(event.Mentions.Roles) != 1 {
+		t.Errorf("got the event's roles changed to %v", event.Mentions.Roles)
+	}
+}
+
+func TestValidateMentions(t *testing.T) {
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("1%017d", i)
+	}
+
+	tests := []struct {
+		name     string
+		mentions Mentions
+		want     map[string]string
+	}{
+		{
+			name:     "valid",
+			mentions: Mentions{Roles: []string{"111111111111111111"}, Users: []string{"33333333333333333333"}, Everyone: true},
+			want:     map[string]string{},
+		},
+		{
+			name:     "not snowflakes",
+			mentions: Mentions{Roles: []string{"moderators"}, Users: []string{"1234", strings.Repeat("1", 21)}},
+			want: map[string]string{
+				"mentions.roles[0]": "must be a Discord role ID",
+				"mentions.users[0]": "must be a Discord user ID",
+				"mentions.users[1]": "must be a Discord user ID",
+			},
+		},
+		{
+			name:     "too many",
+			mentions: Mentions{Roles: tooMany, Users: tooMany},
+			want: map[string]string{
+				"mentions.roles": "must not contain more than 100 roles",
+				"mentions.users": "must not contain more than 100 users",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateMentions(v, tt.mentions)
+
+			if fmt.Sprint(v.Errors) != fmt.Sprint(tt.want) {
+				t.Errorf("got errors %v, want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testMigrationConn returns a connection to the database of GOEVENTBOT_TEST_DSN
+// whose search path is an empty schema, dropped at the end of the test. The
+// test is skipped without a database.
+func testMigrationConn(t *testing.T) *sql.Conn {
+	t.Helper()
+
+	dsn := os.Getenv("GOEVENTBOT_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOEVENTBOT_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := "migrations_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA %s; SET search_path TO %s, public", schema, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		conn.Close()
+	})
+
+	return conn
+}
+
+// migrate runs the up migrations numbered from first to last included.
+func migrate(t *testing.T, conn *sql.Conn, first, last int) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+
+	for _, file := range files {
+		var version int
+		if _, err := fmt.Sscanf(filepath.Base(file), "%d_", &version); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if version < first || version > last {
+			continue
+		}
+
+		query, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = conn.ExecContext(context.Background(), string(query))
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+func TestMigrationNotificationChannels(t *testing.T) {
+	conn := testMigrationConn(t)
+	ctx := context.Background()
+
+	migrate(t, conn, 1, 17)
+
+	var webhookID, withWebhook, withoutWebhook uuid.UUID
+
+	err := conn.QueryRowContext(ctx, `INSERT INTO webhooks (name, url) VALUES ('raids', 'https://discord.com/api/webhooks/1/token') RETURNING id`).Scan(&webhookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []struct {
+		id        *uuid.UUID
+		webhookID *uuid.UUID
+	}{
+		{&withWebhook, &webhookID},
+		{&withoutWebhook, nil},
+	} {
+		err := conn.QueryRowContext(ctx, `
+			INSERT INTO events (title, description, duration, start_date, webhook_id)
+			VALUES ('Raid night', 'Raid night', 'PT1H', NOW(), $1)
+			RETURNING id`, e.webhookID).Scan(e.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = conn.ExecContext(ctx, `INSERT INTO jobs (event_id, execution_date) VALUES ($1, $2)`, *e.id, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrate(t, conn, 18, 18)
+
+	rows, err := conn.QueryContext(ctx, `SELECT event_id, webhook_id FROM jobs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var jobs []string
+	for rows.Next() {
+		var eventID, jobWebhookID uuid.UUID
+		if err := rows.Scan(&eventID, &jobWebhookID); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, fmt.Sprint(eventID, jobWebhookID))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{fmt.Sprint(withWebhook, webhookID)}
+	if !slices.Equal(jobs, want) {
+		t.Errorf("got jobs %v, want only the job of the event with a webhook %v", jobs, want)
+	}
+}
+
+func TestMigrationRSVPsAnswerOrder(t *testing.T) {
+	conn := testMigrationConn(t)
+	ctx := context.Background()
+
+	migrate(t, conn, 1, 28)
+
+	var webhookID, eventID uuid.UUID
+
+	err := conn.QueryRowContext(ctx, `INSERT INTO webhooks (name, url) VALUES ('raids', 'https://discord.com/api/webhooks/1/token') RETURNING id`).Scan(&webhookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.QueryRowContext(ctx, `
+		INSERT INTO events (title, description, duration, start_date, webhook_id, rsvp)
+		VALUES ('Raid night', 'Raid night', 'PT1H', NOW(), $1, true)
+		RETURNING id`, webhookID).Scan(&eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	occurrence := time.Now().Add(time.Hour).Truncate(time.Second)
+	answered := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	for _, r := range []struct {
+		discordUserID string
+		updated       time.Time
+	}{
+		{"third", answered},
+		{"first", answered.Add(-2 * time.Minute)},
+		{"second", answered.Add(-time.Minute)},
+	} {
+		_, err := conn.ExecContext(ctx, `
+			INSERT INTO rsvps (event_id, occurrence_date, discord_user_id, status, updated_date)
+			VALUES ($1, $2, $3, 'yes', $4)`, eventID, occurrence, r.discordUserID, r.updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrate(t, conn, 29, 29)
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO rsvps (event_id, occurrence_date, discord_user_id, status)
+		VALUES ($1, $2, 'fourth', 'yes')`, eventID, occurrence)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT discord_user_id FROM rsvps ORDER BY answer_order`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var order []string
+	for rows.Next() {
+		var discordUserID string
+		if err := rows.Scan(&discordUserID); err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, discordUserID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "third", "fourth"}
+	if !slices.Equal(order, want) {
+		t.Errorf("got the answers in the order %v, want %v", order, want)
+	}
+}
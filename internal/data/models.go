@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
@@ -10,14 +11,26 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// execer runs statements on the database, or within one of its transactions.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type Models struct {
 	Permissions PermissionModel
 	Tokens      TokenModel
 	Users       UserModel
 	Events      EventModel
 	Jobs        JobModel
+	Occurrences OccurrenceModel
+	Reminders   ReminderModel
 	OAuth       OAuthModel
 	Tags        TagModel
+	Webhooks    WebhookModel
+	Calendars   CalendarSyncModel
+	Scheduled   ScheduledEventModel
+	RSVPs       RSVPModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -27,7 +40,13 @@ func NewModels(db *sql.DB) Models {
 		Users:       UserModel{DB: db},
 		Events:      EventModel{DB: db},
 		Jobs:        JobModel{DB: db},
+		Occurrences: OccurrenceModel{DB: db},
+		Reminders:   ReminderModel{DB: db},
 		OAuth:       OAuthModel{},
 		Tags:        TagModel{DB: db},
+		Webhooks:    WebhookModel{DB: db},
+		Calendars:   CalendarSyncModel{DB: db},
+		Scheduled:   ScheduledEventModel{DB: db},
+		RSVPs:       RSVPModel{DB: db},
 	}
 }
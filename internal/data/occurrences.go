@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OccurrenceOverride replaces, for a single occurrence of a recurring event,
+// the fields that are set. OriginalStart is the start the recurrence rule
+// gives to the occurrence and identifies it even once it was moved.
+type OccurrenceOverride struct {
+	EventID       uuid.UUID  `json:"event_id"`
+	OriginalStart time.Time  `json:"original_start"`
+	Cancelled     bool       `json:"cancelled"`
+	Title         *string    `json:"title,omitempty"`
+	Description   *string    `json:"description,omitempty"`
+	StartDate     *time.Time `json:"start_date,omitempty"`
+	Duration      *string    `json:"duration,omitempty"`
+	CreatedDate   time.Time  `json:"created_date"`
+	UpdatedDate   time.Time  `json:"updated_date"`
+}
+
+func ValidateOccurrenceOverride(v *validator.Validator, override *OccurrenceOverride) {
+	if override.Title != nil {
+		v.Check(*override.Title != "", "title", "must not be empty")
+		v.Check(len(*override.Title) <= 100, "title", "must not be more than 100 bytes long")
+	}
+	if override.Description != nil {
+		v.Check(*override.Description != "", "description", "must not be empty")
+	}
+	if override.StartDate != nil {
+		v.Check(!override.StartDate.IsZero(), "start_date", "must be a valid date")
+	}
+	if override.Duration != nil {
+		v.IsValidDurationRule(*override.Duration)
+	}
+}
+
+type OccurrenceModel struct {
+	DB *sql.DB
+}
+
+// SetDates replaces the EXDATE and RDATE lists of the event.
+func (m OccurrenceModel) SetDates(eventID uuid.UUID, exdates, rdates []time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setEventDates(ctx, tx, eventID, exdates, rdates)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setEventDates(ctx context.Context, db execer, eventID uuid.UUID, exdates, rdates []time.Time) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM event_dates WHERE event_id = $1`, eventID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO event_dates (event_id, kind, date)
+		SELECT $1, $2, date FROM unnest($3::timestamptz[]) AS date
+		ON CONFLICT DO NOTHING`
+
+	for kind, dates := range map[string][]time.Time{"exdate": exdates, "rdate": rdates} {
+		if len(dates) == 0 {
+			continue
+		}
+
+		values := make([]string, len(dates))
+		for i, date := range dates {
+			values[i] = date.Format(time.RFC3339)
+		}
+
+		_, err = db.ExecContext(ctx, query, eventID, kind, pq.Array(values))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDatesForEvents returns the EXDATE and RDATE lists of the given events,
+// keyed by event ID.
+func (m OccurrenceModel) GetDatesForEvents(eventIDs []uuid.UUID) (map[uuid.UUID][]time.Time, map[uuid.UUID][]time.Time, error) {
+	query := `
+		SELECT event_id, kind, date
+		FROM event_dates
+		WHERE event_id = ANY($1::uuid[])
+		ORDER BY date`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(uuidStrings(eventIDs)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	exdates := make(map[uuid.UUID][]time.Time)
+	rdates := make(map[uuid.UUID][]time.Time)
+	for rows.Next() {
+		var eventID uuid.UUID
+		var kind string
+		var date time.Time
+		err := rows.Scan(&eventID, &kind, &date)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch kind {
+		case "exdate":
+			exdates[eventID] = append(exdates[eventID], date)
+		case "rdate":
+			rdates[eventID] = append(rdates[eventID], date)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return exdates, rdates, nil
+}
+
+// UpsertOverride stores the override of one occurrence, replacing the
+// previous one of the same occurrence if any.
+func (m OccurrenceModel) UpsertOverride(override *OccurrenceOverride) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return upsertOverride(ctx, m.DB, override)
+}
+
+func upsertOverride(ctx context.Context, db execer, override *OccurrenceOverride) error {
+	query := `
+		INSERT INTO event_overrides (event_id, original_start, cancelled, title, description, start_date, duration)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_id, original_start) DO UPDATE
+		SET cancelled = EXCLUDED.cancelled, title = EXCLUDED.title, description = EXCLUDED.description,
+			start_date = EXCLUDED.start_date, duration = EXCLUDED.duration, updated_date = NOW()
+		RETURNING created_date, updated_date`
+
+	args := []any{
+		override.EventID,
+		override.OriginalStart,
+		override.Cancelled,
+		override.Title,
+		override.Description,
+		override.StartDate,
+		override.Duration,
+	}
+
+	return db.QueryRowContext(ctx, query, args...).Scan(&override.CreatedDate, &override.UpdatedDate)
+}
+
+func (m OccurrenceModel) GetOverride(eventID uuid.UUID, originalStart time.Time) (*OccurrenceOverride, error) {
+	query := `
+		SELECT event_id, original_start, cancelled, title, description, start_date, duration, created_date, updated_date
+		FROM event_overrides
+		WHERE event_id = $1 AND original_start = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	override, err := scanOverride(m.DB.QueryRowContext(ctx, query, eventID, originalStart))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return override, nil
+}
+
+// GetOverridesForEvents returns the overrides of the given events, keyed by
+// event ID.
+func (m OccurrenceModel) GetOverridesForEvents(eventIDs []uuid.UUID) (map[uuid.UUID][]OccurrenceOverride, error) {
+	query := `
+		SELECT event_id, original_start, cancelled, title, description, start_date, duration, created_date, updated_date
+		FROM event_overrides
+		WHERE event_id = ANY($1::uuid[])
+		ORDER BY original_start`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(uuidStrings(eventIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[uuid.UUID][]OccurrenceOverride)
+	for rows.Next() {
+		override, err := scanOverride(rows)
+		if err != nil {
+			return nil, err
+		}
+		overrides[override.EventID] = append(overrides[override.EventID], *override)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+func scanOverride(row interface{ Scan(...any) error }) (*OccurrenceOverride, error) {
+	var override OccurrenceOverride
+	var title, description, duration sql.NullString
+	var startDate sql.NullTime
+
+	err := row.Scan(
+		&override.EventID,
+		&override.OriginalStart,
+		&override.Cancelled,
+		&title,
+		&description,
+		&startDate,
+		&duration,
+		&override.CreatedDate,
+		&override.UpdatedDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if title.Valid {
+		override.Title = &title.String
+	}
+	if description.Valid {
+		override.Description = &description.String
+	}
+	if startDate.Valid {
+		override.StartDate = &startDate.Time
+	}
+	if duration.Valid {
+		override.Duration = &duration.String
+	}
+
+	return &override, nil
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = id.String()
+	}
+	return s
+}
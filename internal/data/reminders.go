@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	duration "github.com/channelmeter/iso8601duration"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// The point of an occurrence a reminder is relative to.
+const (
+	ReminderStart = "start"
+	ReminderEnd   = "end"
+)
+
+// Reminder is an announcement of every occurrence of an event, sent at
+// Offset from the start or the end of the occurrence. Offset is a signed ISO
+// 8601 duration, -PT1H is an hour before. Template replaces the event's one
+// for the reminder.
+type Reminder struct {
+	ID          uuid.UUID        `json:"id"`
+	EventID     uuid.UUID        `json:"event_id"`
+	Offset      string           `json:"offset"`
+	RelativeTo  string           `json:"relative_to"`
+	Template    *MessageTemplate `json:"template,omitempty"`
+	CreatedDate time.Time        `json:"created_date"`
+	UpdatedDate time.Time        `json:"updated_date"`
+}
+
+// ParseOffset returns the offset as a duration, negative before the point of
+// the occurrence it is relative to.
+func (r Reminder) ParseOffset() (time.Duration, error) {
+	s, negative := strings.CutPrefix(r.Offset, "-")
+	if !negative {
+		s = strings.TrimPrefix(s, "+")
+	}
+
+	d, err := duration.FromString(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if negative {
+		return -d.ToDuration(), nil
+	}
+
+	return d.ToDuration(), nil
+}
+
+// FireDate returns when the reminder of the occurrence going from start to
+// end is sent.
+func (r Reminder) FireDate(start, end time.Time) (time.Time, error) {
+	offset, err := r.ParseOffset()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if r.RelativeTo == ReminderEnd {
+		return end.Add(offset), nil
+	}
+
+	return start.Add(offset), nil
+}
+
+func ValidateReminder(v *validator.Validator, reminder *Reminder) {
+	v.Check(reminder.EventID != uuid.Nil, "event_id", "must be provided")
+	v.Check(reminder.Offset != "", "offset", "must be provided")
+	if reminder.Offset != "" {
+		_, err := reminder.ParseOffset()
+		v.Check(err == nil, "offset", "must be a signed ISO 8601 duration such as -PT1H")
+	}
+	v.Check(validator.PermittedValue(reminder.RelativeTo, ReminderStart, ReminderEnd), "relative_to", "must be start or end")
+
+	if reminder.Template != nil {
+		ValidateMessageTemplate(v, reminder.Template)
+	}
+}
+
+type ReminderModel struct {
+	DB *sql.DB
+}
+
+func (m ReminderModel) Insert(reminder *Reminder) error {
+	query := `
+		INSERT INTO reminders (event_id, time_offset, relative_to, message_template)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_date, updated_date`
+
+	args := []any{reminder.EventID, reminder.Offset, reminder.RelativeTo, reminder.Template}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&reminder.ID, &reminder.CreatedDate, &reminder.UpdatedDate)
+}
+
+func (m ReminderModel) Get(id uuid.UUID) (*Reminder, error) {
+	query := `
+		SELECT id, event_id, time_offset, relative_to, message_template, created_date, updated_date
+		FROM reminders
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reminder Reminder
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&reminder.ID,
+		&reminder.EventID,
+		&reminder.Offset,
+		&reminder.RelativeTo,
+		templateScanner{&reminder.Template},
+		&reminder.CreatedDate,
+		&reminder.UpdatedDate,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &reminder, nil
+}
+
+// GetAllForEvents returns the reminders of each of the given events, keyed by
+// event ID.
+func (m ReminderModel) GetAllForEvents(eventIDs []uuid.UUID) (map[uuid.UUID][]Reminder, error) {
+	query := `
+		SELECT id, event_id, time_offset, relative_to, message_template, created_date, updated_date
+		FROM reminders
+		WHERE event_id = ANY($1::uuid[])
+		ORDER BY created_date, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(uuidStrings(eventIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := make(map[uuid.UUID][]Reminder)
+	for rows.Next() {
+		var reminder Reminder
+		err := rows.Scan(
+			&reminder.ID,
+			&reminder.EventID,
+			&reminder.Offset,
+			&reminder.RelativeTo,
+			templateScanner{&reminder.Template},
+			&reminder.CreatedDate,
+			&reminder.UpdatedDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reminders[reminder.EventID] = append(reminders[reminder.EventID], reminder)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (m ReminderModel) Update(reminder *Reminder) error {
+	query := `
+		UPDATE reminders
+		SET time_offset = $1, relative_to = $2, message_template = $3, updated_date = NOW()
+		WHERE id = $4
+		RETURNING updated_date`
+
+	args := []any{reminder.Offset, reminder.RelativeTo, reminder.Template, reminder.ID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&reminder.UpdatedDate)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the reminder along with its jobs.
+func (m ReminderModel) Delete(id uuid.UUID) error {
+	query := `DELETE FROM reminders WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+)
+
+// The answers to an RSVP.
+const (
+	RSVPYes   = "yes"
+	RSVPNo    = "no"
+	RSVPMaybe = "maybe"
+)
+
+// RSVP is the answer of a user, or of a Discord user without an account, to
+// the occurrence of an event the rule starts at OccurrenceDate. The users
+// answering yes past the capacity of the event are Waitlisted, in the order
+// they answered, and move up when someone in front of them stops coming.
+type RSVP struct {
+	ID             uuid.UUID  `json:"id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	OccurrenceDate time.Time  `json:"occurrence_date"`
+	UserID         *uuid.UUID `json:"user_id,omitempty"`
+	DiscordUserID  string     `json:"discord_user_id,omitempty"`
+	Status         string     `json:"status"`
+	Waitlisted     bool       `json:"waitlisted"`
+	CreatedDate    time.Time  `json:"created_date"`
+	UpdatedDate    time.Time  `json:"updated_date"`
+}
+
+// Attendance counts the RSVPs to an occurrence. Going stops at Capacity, the
+// other users answering yes are Waitlisted. A zero Capacity is unlimited.
+type Attendance struct {
+	Going      int `json:"going"`
+	Maybe      int `json:"maybe"`
+	NotGoing   int `json:"not_going"`
+	Waitlisted int `json:"waitlisted"`
+	Capacity   int `json:"capacity"`
+}
+
+// Full reports whether the next user answering yes is waitlisted.
+func (a Attendance) Full() bool {
+	return a.Capacity > 0 && a.Going >= a.Capacity
+}
+
+func ValidateRSVP(v *validator.Validator, rsvp *RSVP) {
+	v.Check(rsvp.Status != "", "status", "must be provided")
+	v.Check(validator.PermittedValue(rsvp.Status, RSVPYes, RSVPNo, RSVPMaybe), "status", "must be yes, no or maybe")
+	v.Check((rsvp.UserID == nil) != (rsvp.DiscordUserID == ""), "user", "must be either a user or a Discord user")
+}
+
+type RSVPModel struct {
+	DB *sql.DB
+}
+
+// Upsert records the answer of the user to the occurrence, replacing their
+// previous one, and tells whether they are waitlisted given the capacity of
+// the event. Answering yes again keeps the user's place in the queue.
+func (m RSVPModel) Upsert(rsvp *RSVP, capacity int) error {
+	conflict := "(event_id, occurrence_date, user_id) WHERE user_id IS NOT NULL"
+	if rsvp.UserID == nil {
+		conflict = "(event_id, occurrence_date, discord_user_id) WHERE discord_user_id IS NOT NULL"
+	}
+
+	query := `
+		INSERT INTO rsvps (event_id, occurrence_date, user_id, discord_user_id, status)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT ` + conflict + ` DO UPDATE SET
+			status = EXCLUDED.status,
+			updated_date = CASE WHEN rsvps.status = EXCLUDED.status THEN rsvps.updated_date ELSE NOW() END,
+			answer_order = CASE WHEN rsvps.status = EXCLUDED.status THEN rsvps.answer_order ELSE EXCLUDED.answer_order END
+		RETURNING id, occurrence_date, created_date, updated_date, answer_order`
+
+	args := []any{rsvp.EventID, rsvp.OccurrenceDate.UTC().Truncate(time.Second), rsvp.UserID, rsvp.DiscordUserID, rsvp.Status}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var answerOrder int64
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&rsvp.ID, &rsvp.OccurrenceDate, &rsvp.CreatedDate, &rsvp.UpdatedDate, &answerOrder)
+	if err != nil {
+		return err
+	}
+
+	rsvp.Waitlisted = false
+	if rsvp.Status != RSVPYes || capacity == 0 {
+		return nil
+	}
+
+	query = `
+		SELECT count(*)
+		FROM rsvps
+		WHERE event_id = $1 AND occurrence_date = $2 AND status = 'yes' AND answer_order < $3`
+
+	var ahead int
+
+	err = m.DB.QueryRowContext(ctx, query, rsvp.EventID, rsvp.OccurrenceDate, answerOrder).Scan(&ahead)
+	if err != nil {
+		return err
+	}
+
+	rsvp.Waitlisted = ahead >= capacity
+
+	return nil
+}
+
+// GetAllForOccurrence returns the RSVPs to the occurrence in the order they
+// were answered, the users answering yes past capacity being waitlisted.
+func (m RSVPModel) GetAllForOccurrence(eventID uuid.UUID, occurrenceDate time.Time, capacity int) ([]RSVP, error) {
+	query := `
+		SELECT id, event_id, occurrence_date, user_id, COALESCE(discord_user_id, ''), status, created_date, updated_date,
+			status = 'yes' AND $3 > 0 AND row_number() OVER (PARTITION BY status ORDER BY answer_order) > $3
+		FROM rsvps
+		WHERE event_id = $1 AND occurrence_date = $2
+		ORDER BY answer_order`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, eventID, occurrenceDate.UTC().Truncate(time.Second), capacity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rsvps := []RSVP{}
+	for rows.Next() {
+		var rsvp RSVP
+		err := rows.Scan(
+			&rsvp.ID,
+			&rsvp.EventID,
+			&rsvp.OccurrenceDate,
+			&rsvp.UserID,
+			&rsvp.DiscordUserID,
+			&rsvp.Status,
+			&rsvp.CreatedDate,
+			&rsvp.UpdatedDate,
+			&rsvp.Waitlisted,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rsvps = append(rsvps, rsvp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rsvps, nil
+}
+
+// GetAttendance counts the RSVPs to the occurrence.
+func (m RSVPModel) GetAttendance(eventID uuid.UUID, occurrenceDate time.Time, capacity int) (Attendance, error) {
+	query := `
+		SELECT
+			count(*) FILTER (WHERE status = 'yes'),
+			count(*) FILTER (WHERE status = 'maybe'),
+			count(*) FILTER (WHERE status = 'no')
+		FROM rsvps
+		WHERE event_id = $1 AND occurrence_date = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	a := Attendance{Capacity: capacity}
+
+	var yes int
+
+	err := m.DB.QueryRowContext(ctx, query, eventID, occurrenceDate.UTC().Truncate(time.Second)).Scan(&yes, &a.Maybe, &a.NotGoing)
+	if err != nil {
+		return Attendance{}, err
+	}
+
+	a.Going = yes
+	if capacity > 0 && yes > capacity {
+		a.Going = capacity
+		a.Waitlisted = yes - capacity
+	}
+
+	return a, nil
+}
+
+// Delete removes the answer of the user of rsvp to its occurrence.
+func (m RSVPModel) Delete(rsvp *RSVP) error {
+	query := `
+		DELETE FROM rsvps
+		WHERE event_id = $1 AND occurrence_date = $2 AND (user_id = $3 OR discord_user_id = NULLIF($4, ''))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, rsvp.EventID, rsvp.OccurrenceDate.UTC().Truncate(time.Second), rsvp.UserID, rsvp.DiscordUserID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduledEvent is the Discord guild scheduled event mirroring the
+// occurrence of an event the rule starts at OccurrenceDate. DiscordID is
+// empty while the scheduled event is being created.
+type ScheduledEvent struct {
+	EventID        uuid.UUID `json:"event_id"`
+	OccurrenceDate time.Time `json:"occurrence_date"`
+	DiscordID      string    `json:"discord_id"`
+	CreatedDate    time.Time `json:"created_date"`
+	UpdatedDate    time.Time `json:"updated_date"`
+}
+
+type ScheduledEventModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims the occurrence of the event for the caller to create its
+// scheduled event, and reports whether it got it. The occurrences already
+// mirrored, or being mirrored by another synchronization, are not reserved.
+// A reservation left without Discord ID for 5 minutes is taken over, its
+// holder having failed to create the scheduled event.
+func (m ScheduledEventModel) Reserve(eventID uuid.UUID, occurrenceDate time.Time) (bool, error) {
+	query := `
+		INSERT INTO scheduled_events (event_id, occurrence_date)
+		VALUES ($1, $2)
+		ON CONFLICT (event_id, occurrence_date) DO UPDATE SET updated_date = NOW()
+		WHERE scheduled_events.discord_id IS NULL AND scheduled_events.updated_date < NOW() - INTERVAL '5 minutes'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, eventID, occurrenceDate.UTC().Truncate(time.Second))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// SetDiscordID records the ID of the scheduled event created for the
+// reservation. ErrRecordNotFound is returned when the reservation was
+// withdrawn in the meantime, the occurrence being no longer mirrored.
+func (m ScheduledEventModel) SetDiscordID(eventID uuid.UUID, occurrenceDate time.Time, discordID string) error {
+	query := `
+		UPDATE scheduled_events
+		SET discord_id = $3, updated_date = NOW()
+		WHERE event_id = $1 AND occurrence_date = $2 AND discord_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, eventID, occurrenceDate.UTC().Truncate(time.Second), discordID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForEvent returns the scheduled events mirroring the occurrences of
+// the event, the earliest first.
+func (m ScheduledEventModel) GetAllForEvent(eventID uuid.UUID) ([]ScheduledEvent, error) {
+	query := `
+		SELECT event_id, occurrence_date, COALESCE(discord_id, ''), created_date, updated_date
+		FROM scheduled_events
+		WHERE event_id = $1
+		ORDER BY occurrence_date`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scheduled []ScheduledEvent
+	for rows.Next() {
+		var s ScheduledEvent
+		err := rows.Scan(&s.EventID, &s.OccurrenceDate, &s.DiscordID, &s.CreatedDate, &s.UpdatedDate)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+func (m ScheduledEventModel) Delete(eventID uuid.UUID, occurrenceDate time.Time) error {
+	query := `DELETE FROM scheduled_events WHERE event_id = $1 AND occurrence_date = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, eventID, occurrenceDate.UTC().Truncate(time.Second))
+	return err
+}
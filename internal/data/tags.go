@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"time"
 )
 
@@ -13,6 +15,7 @@ type Tag struct {
 	ID          uuid.UUID `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Mentions    Mentions  `json:"mentions"`
 	CreatedDate time.Time `json:"created_date"`
 	UpdatedDate time.Time `json:"updated_date"`
 }
@@ -22,6 +25,7 @@ func ValidateTag(v *validator.Validator, tag *Tag) {
 	v.Check(len(tag.Name) <= 20, "name", "must not be more than 20 characters long")
 	v.Check(tag.Description != "", "description", "must be provided")
 	v.Check(len(tag.Description) <= 100, "description", "must not be more than 100 characters long")
+	ValidateMentions(v, tag.Mentions)
 }
 
 type TagModel struct {
@@ -29,11 +33,11 @@ type TagModel struct {
 }
 
 func (t TagModel) Insert(tag *Tag) error {
-	query := `INSERT INTO tags (name, description) VALUES ($1, $2) RETURNING id, created_date, updated_date`
+	query := `INSERT INTO tags (name, description, mentions) VALUES ($1, $2, $3) RETURNING id, created_date, updated_date`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := t.DB.QueryRowContext(ctx, query, tag.Name, tag.Description).Scan(&tag.ID, &tag.CreatedDate, &tag.UpdatedDate)
+	err := t.DB.QueryRowContext(ctx, query, tag.Name, tag.Description, tag.Mentions).Scan(&tag.ID, &tag.CreatedDate, &tag.UpdatedDate)
 
 	if err != nil {
 		return err
@@ -43,12 +47,12 @@ func (t TagModel) Insert(tag *Tag) error {
 }
 
 func (t TagModel) GetByID(id uuid.UUID) (*Tag, error) {
-	query := `SELECT id, name, description, created_date, updated_date FROM tags WHERE id = $1`
+	query := `SELECT id, name, description, mentions, created_date, updated_date FROM tags WHERE id = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	tag := &Tag{}
-	err := t.DB.QueryRowContext(ctx, query, id).Scan(&tag.ID, &tag.Name, &tag.Description, &tag.CreatedDate, &tag.UpdatedDate)
+	err := t.DB.QueryRowContext(ctx, query, id).Scan(&tag.ID, &tag.Name, &tag.Description, &tag.Mentions, &tag.CreatedDate, &tag.UpdatedDate)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -61,11 +65,11 @@ func (t TagModel) GetByID(id uuid.UUID) (*Tag, error) {
 }
 
 func (t TagModel) Update(tag *Tag) error {
-	query := `UPDATE tags SET name = $1, description = $2, updated_date = NOW() WHERE id = $3`
+	query := `UPDATE tags SET name = $1, description = $2, mentions = $3, updated_date = NOW() WHERE id = $4`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := t.DB.ExecContext(ctx, query, tag.Name, tag.Description, tag.ID)
+	result, err := t.DB.ExecContext(ctx, query, tag.Name, tag.Description, tag.Mentions, tag.ID)
 	if err != nil {
 		return err
 	}
@@ -110,27 +114,105 @@ func (t TagModel) Delete(id uuid.UUID) error {
 	return nil
 }
 
-func (t TagModel) GetAll() ([]Tag, error) {
-	query := `SELECT id, name, description, created_date, updated_date FROM tags`
+func (t TagModel) GetAll(filters Filters) ([]Tag, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, name, COALESCE(description, ''), mentions, created_date, updated_date
+		FROM tags
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := t.DB.QueryContext(ctx, query)
+	rows, err := t.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&totalRecords, &tag.ID, &tag.Name, &tag.Description, &tag.Mentions, &tag.CreatedDate, &tag.UpdatedDate)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return tags, metadata, nil
+}
+
+func (t TagModel) AddForEvent(eventID, tagID uuid.UUID) error {
+	query := `INSERT INTO event_tags (event_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := t.DB.ExecContext(ctx, query, eventID, tagID)
+	return err
+}
+
+func (t TagModel) DeleteForEvent(eventID, tagID uuid.UUID) error {
+	query := `DELETE FROM event_tags WHERE event_id = $1 AND tag_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := t.DB.ExecContext(ctx, query, eventID, tagID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForEvents returns the tags of each of the given events, keyed by
+// event ID.
+func (t TagModel) GetAllForEvents(eventIDs []uuid.UUID) (map[uuid.UUID][]Tag, error) {
+	query := `
+		SELECT event_tags.event_id, tags.id, tags.name, COALESCE(tags.description, ''), tags.mentions, tags.created_date, tags.updated_date
+		FROM tags
+		INNER JOIN event_tags ON event_tags.tag_id = tags.id
+		WHERE event_tags.event_id = ANY($1::uuid[])
+		ORDER BY tags.name`
+
+	ids := make([]string, len(eventIDs))
+	for i, id := range eventIDs {
+		ids[i] = id.String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
-	defer func(rows *sql.Rows) {
-		err = rows.Close()
-	}(rows)
+	defer rows.Close()
 
-	var tags []Tag
+	tags := make(map[uuid.UUID][]Tag)
 	for rows.Next() {
+		var eventID uuid.UUID
 		var tag Tag
-		err := rows.Scan(&tag.ID, &tag.Name, &tag.Description, &tag.CreatedDate, &tag.UpdatedDate)
+		err := rows.Scan(&eventID, &tag.ID, &tag.Name, &tag.Description, &tag.Mentions, &tag.CreatedDate, &tag.UpdatedDate)
 		if err != nil {
 			return nil, err
 		}
-		tags = append(tags, tag)
+		tags[eventID] = append(tags[eventID], tag)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+)
+
+var colorRX = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// MessageTemplate customizes the announcement of an event. Every string is a
+// text/template executed against the occurrence being announced, and the
+// ones left empty keep the default message. Color is a hex RGB color such as
+// #E67E22.
+type MessageTemplate struct {
+	Content      string          `json:"content,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	URL          string          `json:"url,omitempty"`
+	Color        string          `json:"color,omitempty"`
+	Fields       []TemplateField `json:"fields,omitempty"`
+	Footer       string          `json:"footer,omitempty"`
+	ThumbnailURL string          `json:"thumbnail_url,omitempty"`
+	Author       *TemplateAuthor `json:"author,omitempty"`
+}
+
+type TemplateField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type TemplateAuthor struct {
+	Name    string `json:"name,omitempty"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// IsZero reports whether the template changes nothing to the default message.
+func (t MessageTemplate) IsZero() bool {
+	return t.Content == "" && t.Title == "" && t.Description == "" && t.URL == "" &&
+		t.Color == "" && len(t.Fields) == 0 && t.Footer == "" && t.ThumbnailURL == "" &&
+		(t.Author == nil || *t.Author == (TemplateAuthor{}))
+}
+
+// ValidateMessageTemplate checks the shape of the template, the templates
+// themselves are checked by the code executing them. The limits are
+// Discord's.
+func ValidateMessageTemplate(v *validator.Validator, t *MessageTemplate) {
+	if t.Color != "" {
+		v.Check(validator.Matches(t.Color, colorRX), "template.color", "must be a hex color such as #E67E22")
+	}
+
+	v.Check(len(t.Fields) <= 25, "template.fields", "must not contain more than 25 fields")
+	for i, field := range t.Fields {
+		v.Check(field.Name != "", fmt.Sprintf("template.fields[%d].name", i), "must be provided")
+		v.Check(field.Value != "", fmt.Sprintf("template.fields[%d].value", i), "must be provided")
+	}
+}
+
+func (t MessageTemplate) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// templateScanner scans a nullable message_template column, NULL leaves the
+// template nil.
+type templateScanner struct {
+	dst **MessageTemplate
+}
+
+func (s templateScanner) Scan(src any) error {
+	if src == nil {
+		*s.dst = nil
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("message_template must be scanned from bytes")
+	}
+
+	var t MessageTemplate
+	err := json.Unmarshal(b, &t)
+	if err != nil {
+		return err
+	}
+
+	*s.dst = &t
+	return nil
+}
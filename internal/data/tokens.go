@@ -16,6 +16,7 @@ const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password-reset"
+	ScopeCalendarFeed   = "calendar-feed"
 )
 
 type Token struct {
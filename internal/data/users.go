@@ -14,7 +14,8 @@ import (
 )
 
 var (
-	ErrDuplicateEmail = errors.New("duplicate email")
+	ErrDuplicateEmail     = errors.New("duplicate email")
+	ErrDuplicateDiscordID = errors.New("duplicate discord id")
 )
 
 var AnonymousUser = &User{}
@@ -26,6 +27,7 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
+	DiscordID string    `json:"discord_id,omitempty"`
 	Version   int       `json:"-"`
 }
 
@@ -75,6 +77,10 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
 }
 
+func ValidateDiscordID(v *validator.Validator, discordID string) {
+	v.Check(validator.Matches(discordID, snowflakeRX), "discord_id", "must be a Discord user ID")
+}
+
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
@@ -96,11 +102,11 @@ type UserModel struct {
 
 func (m UserModel) Insert(user *User) error {
 	query := `
-        INSERT INTO users (name, email, password_hash, activated) 
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO users (name, email, password_hash, activated, discord_id) 
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''))
         RETURNING id, created_at, version`
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.DiscordID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -110,6 +116,8 @@ func (m UserModel) Insert(user *User) error {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_discord_id_key"`:
+			return ErrDuplicateDiscordID
 		default:
 			return err
 		}
@@ -120,7 +128,7 @@ func (m UserModel) Insert(user *User) error {
 
 func (m UserModel) GetAll() ([]*User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, COALESCE(discord_id, ''), version
 		FROM users
 		ORDER BY id`
 
@@ -144,6 +152,7 @@ func (m UserModel) GetAll() ([]*User, error) {
 			&user.Email,
 			&user.Password.hash,
 			&user.Activated,
+			&user.DiscordID,
 			&user.Version,
 		)
 		if err != nil {
@@ -178,7 +187,7 @@ func (m UserModel) ValidateIsFirstUser() bool {
 }
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-        SELECT id, created_at, name, email, password_hash, activated, version
+        SELECT id, created_at, name, email, password_hash, activated, COALESCE(discord_id, ''), version
         FROM users
         WHERE email = $1`
 
@@ -194,6 +203,43 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.DiscordID,
+		&user.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// GetByDiscordID returns the user whose account is linked to the Discord
+// user.
+func (m UserModel) GetByDiscordID(discordID string) (*User, error) {
+	query := `
+        SELECT id, created_at, name, email, password_hash, activated, COALESCE(discord_id, ''), version
+        FROM users
+        WHERE discord_id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, discordID).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.DiscordID,
 		&user.Version,
 	)
 
@@ -212,8 +258,8 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 func (m UserModel) Update(user *User) error {
 	query := `
         UPDATE users 
-        SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-        WHERE id = $5 AND version = $6
+        SET name = $1, email = $2, password_hash = $3, activated = $4, discord_id = NULLIF($5, ''), version = version + 1
+        WHERE id = $6 AND version = $7
         RETURNING version`
 
 	args := []any{
@@ -221,6 +267,7 @@ func (m UserModel) Update(user *User) error {
 		user.Email,
 		user.Password.hash,
 		user.Activated,
+		user.DiscordID,
 		user.ID,
 		user.Version,
 	}
@@ -233,6 +280,8 @@ func (m UserModel) Update(user *User) error {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_discord_id_key"`:
+			return ErrDuplicateDiscordID
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
@@ -247,7 +296,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	query := `
-        SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+        SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, COALESCE(users.discord_id, ''), users.version
         FROM users
         INNER JOIN tokens
         ON users.id = tokens.user_id
@@ -269,6 +318,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.DiscordID,
 		&user.Version,
 	)
 	if err != nil {
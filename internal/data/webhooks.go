@@ -0,0 +1,304 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Markaplay-Game-Hosting/GoEventBot/internal/validator"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The kinds of channel a webhook posts to.
+const (
+	ChannelDiscord = "discord"
+	ChannelSlack   = "slack"
+	ChannelTeams   = "teams"
+	ChannelMatrix  = "matrix"
+	ChannelJSON    = "json"
+)
+
+var ChannelTypes = []string{ChannelDiscord, ChannelSlack, ChannelTeams, ChannelMatrix, ChannelJSON}
+
+// channelURLs holds, for each channel type, the pattern its URLs follow and
+// the error reported when they do not.
+var channelURLs = map[string]struct {
+	rx      *regexp.Regexp
+	message string
+}{
+	ChannelDiscord: {validator.UrlWebhookRX, "must be a valid Discord webhook URL"},
+	ChannelSlack:   {validator.UrlSlackWebhookRX, "must be a valid Slack incoming webhook URL"},
+	ChannelTeams:   {validator.UrlTeamsWebhookRX, "must be a valid Microsoft Teams webhook URL"},
+	ChannelMatrix:  {validator.UrlMatrixWebhookRX, "must be a Matrix room send URL with an access_token"},
+	ChannelJSON:    {validator.UrlHTTPSRX, "must be a valid HTTPS URL"},
+}
+
+// Webhook is a channel the events are announced on. ApplicationOwned marks
+// the Discord webhooks created by the application, the only ones Discord
+// lets send buttons.
+type Webhook struct {
+	ID               uuid.UUID        `json:"id"`
+	Name             string           `json:"name"`
+	ChannelType      string           `json:"channel_type"`
+	URL              string           `json:"url"`
+	Template         *MessageTemplate `json:"template,omitempty"`
+	ApplicationOwned bool             `json:"application_owned"`
+}
+
+// MaskedURL returns the webhook URL with its token hidden, the token alone is
+// enough to post to the channel so it never leaves the API. The token is the
+// query string when there is one, the last path segment otherwise.
+func (w Webhook) MaskedURL() string {
+	if i := strings.Index(w.URL, "?"); i >= 0 {
+		return w.URL[:i+1] + "********"
+	}
+
+	i := strings.LastIndex(w.URL, "/")
+	if i < 0 {
+		return w.URL
+	}
+
+	return w.URL[:i+1] + "********"
+}
+
+func (w Webhook) MarshalJSON() ([]byte, error) {
+	type webhook Webhook
+
+	masked := webhook(w)
+	masked.URL = w.MaskedURL()
+
+	return json.Marshal(masked)
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	v.Check(webhook.Name != "", "name", "must be provided")
+	v.Check(len(webhook.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(validator.PermittedValue(webhook.ChannelType, ChannelTypes...), "channel_type", "must be one of "+strings.Join(ChannelTypes, ", "))
+	v.Check(webhook.URL != "", "url", "must be provided")
+
+	if url, ok := channelURLs[webhook.ChannelType]; ok {
+		v.Check(validator.Matches(webhook.URL, url.rx), "url", url.message)
+	}
+
+	if webhook.Template != nil {
+		ValidateMessageTemplate(v, webhook.Template)
+	}
+
+	v.Check(!webhook.ApplicationOwned || webhook.ChannelType == ChannelDiscord, "application_owned", "must only be set on Discord webhooks")
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `INSERT INTO webhooks (name, channel_type, url, message_template, application_owned) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, webhook.Name, webhook.ChannelType, webhook.URL, webhook.Template, webhook.ApplicationOwned).Scan(&webhook.ID)
+}
+
+func (m WebhookModel) GetByID(id uuid.UUID) (*Webhook, error) {
+	query := `SELECT id, name, channel_type, url, message_template, application_owned FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&webhook.ID, &webhook.Name, &webhook.ChannelType, &webhook.URL, templateScanner{&webhook.Template}, &webhook.ApplicationOwned)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAll(filters Filters) ([]Webhook, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, name, channel_type, url, message_template, application_owned
+		FROM webhooks
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(&totalRecords, &webhook.ID, &webhook.Name, &webhook.ChannelType, &webhook.URL, templateScanner{&webhook.Template}, &webhook.ApplicationOwned)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return webhooks, metadata, nil
+}
+
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `UPDATE webhooks SET name = $1, channel_type = $2, url = $3, message_template = $4, application_owned = $5 WHERE id = $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, webhook.Name, webhook.ChannelType, webhook.URL, webhook.Template, webhook.ApplicationOwned, webhook.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes the webhook. The events announced primarily on it are
+// removed along with it by the foreign key, callers are expected to check
+// CountActiveEvents first. The other events simply stop being announced on
+// it.
+func (m WebhookModel) Delete(id uuid.UUID) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// CountActiveEvents counts the active events announced on the webhook, as
+// their own webhook or as an additional one.
+func (m WebhookModel) CountActiveEvents(id uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM events
+		WHERE is_active = true
+		AND (webhook_id = $1 OR EXISTS (
+			SELECT 1 FROM event_webhooks
+			WHERE event_webhooks.event_id = events.id AND event_webhooks.webhook_id = $1
+		))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&count)
+
+	return count, err
+}
+
+// AddForEvent announces the event on the webhook too, on top of its own
+// webhook.
+func (m WebhookModel) AddForEvent(eventID, webhookID uuid.UUID) error {
+	query := `INSERT INTO event_webhooks (event_id, webhook_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, eventID, webhookID)
+	return err
+}
+
+func (m WebhookModel) DeleteForEvent(eventID, webhookID uuid.UUID) error {
+	query := `DELETE FROM event_webhooks WHERE event_id = $1 AND webhook_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, eventID, webhookID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetIDsForEvents returns the IDs of the webhooks added to each of the given
+// events, keyed by event ID.
+func (m WebhookModel) GetIDsForEvents(eventIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	query := `
+		SELECT event_id, webhook_id
+		FROM event_webhooks
+		WHERE event_id = ANY($1::uuid[])
+		ORDER BY webhook_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(uuidStrings(eventIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make(map[uuid.UUID][]uuid.UUID)
+	for rows.Next() {
+		var eventID, webhookID uuid.UUID
+		err := rows.Scan(&eventID, &webhookID)
+		if err != nil {
+			return nil, err
+		}
+		webhooks[eventID] = append(webhooks[eventID], webhookID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
@@ -7,8 +7,12 @@ import (
 )
 
 var (
-	EmailRX      = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
-	UrlWebhookRX = regexp.MustCompile(`^https://discord\.com/api/webhooks/\d+/.{68}$`)
+	EmailRX            = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+	UrlWebhookRX       = regexp.MustCompile(`^https://discord\.com/api/webhooks/\d+/.{68}$`)
+	UrlSlackWebhookRX  = regexp.MustCompile(`^https://hooks\.slack\.com/services/[A-Z0-9]+/[A-Z0-9]+/[A-Za-z0-9]+$`)
+	UrlTeamsWebhookRX  = regexp.MustCompile(`^https://[a-z0-9.-]+\.(webhook\.office\.com|logic\.azure\.com|powerplatform\.com)(:443)?/\S+$`)
+	UrlMatrixWebhookRX = regexp.MustCompile(`^https://[^/\s]+/_matrix/client/(r0|v3)/rooms/[^/\s]+/send/m\.room\.message\?access_token=\S+$`)
+	UrlHTTPSRX         = regexp.MustCompile(`^https://[^/\s]+(/\S*)?$`)
 )
 
 type Validator struct {
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS jobs_status_execution_date_idx;
+DROP INDEX IF EXISTS jobs_event_id_execution_date_idx;
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS created_date,
+    DROP COLUMN IF EXISTS updated_date;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS last_error text NULL,
+    ADD COLUMN IF NOT EXISTS created_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_event_id_execution_date_idx ON jobs (event_id, execution_date);
+CREATE INDEX IF NOT EXISTS jobs_status_execution_date_idx ON jobs (status, execution_date);
\ No newline at end of file
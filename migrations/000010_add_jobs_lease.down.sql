@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS jobs_status_lease_expires_at_idx;
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS locked_by,
+    DROP COLUMN IF EXISTS lease_expires_at;
\ No newline at end of file
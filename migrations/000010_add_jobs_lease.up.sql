@@ -0,0 +1,5 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS locked_by text NULL,
+    ADD COLUMN IF NOT EXISTS lease_expires_at timestamp(0) with time zone NULL;
+
+CREATE INDEX IF NOT EXISTS jobs_status_lease_expires_at_idx ON jobs (status, lease_expires_at);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS max_attempts,
+    DROP COLUMN IF EXISTS next_attempt_at;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS max_attempts integer NOT NULL DEFAULT 5,
+    ADD COLUMN IF NOT EXISTS next_attempt_at timestamp(0) with time zone NULL;
\ No newline at end of file
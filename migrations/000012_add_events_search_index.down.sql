@@ -0,0 +1 @@
+DROP INDEX IF EXISTS events_search_idx;
\ No newline at end of file
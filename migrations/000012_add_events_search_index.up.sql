@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING GIN (to_tsvector('simple', title || ' ' || description));
\ No newline at end of file
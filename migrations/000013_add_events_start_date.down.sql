@@ -0,0 +1,3 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS start_date,
+    DROP COLUMN IF EXISTS timezone;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS start_date timestamp(0) with time zone NULL,
+    ADD COLUMN IF NOT EXISTS timezone text NOT NULL DEFAULT 'UTC';
+
+UPDATE events SET start_date = created_date WHERE start_date IS NULL;
+
+ALTER TABLE events
+    ALTER COLUMN start_date SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS event_overrides;
+DROP TABLE IF EXISTS event_dates;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS event_dates (
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('exdate', 'rdate')),
+    date timestamp(0) with time zone NOT NULL,
+    PRIMARY KEY (event_id, kind, date)
+);
+
+CREATE TABLE IF NOT EXISTS event_overrides (
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    original_start timestamp(0) with time zone NOT NULL,
+    cancelled boolean NOT NULL DEFAULT false,
+    title text NULL,
+    description text NULL,
+    start_date timestamp(0) with time zone NULL,
+    duration text NULL,
+    created_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, original_start)
+);
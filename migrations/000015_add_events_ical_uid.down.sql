@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS events_ical_uid_idx;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS ical_uid;
@@ -0,0 +1,4 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS ical_uid text NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_ical_uid_idx ON events (ical_uid) WHERE ical_uid IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS events_source_external_id_idx;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS external_id;
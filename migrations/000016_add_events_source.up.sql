@@ -0,0 +1,5 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS source text NULL,
+    ADD COLUMN IF NOT EXISTS external_id text NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_source_external_id_idx ON events (source, external_id) WHERE external_id IS NOT NULL;
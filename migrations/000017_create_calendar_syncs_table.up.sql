@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS calendar_syncs (
+    source text PRIMARY KEY,
+    sync_cursor text NOT NULL,
+    updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
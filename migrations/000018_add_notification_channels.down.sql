@@ -0,0 +1,12 @@
+DELETE FROM jobs USING events WHERE events.id = jobs.event_id AND jobs.webhook_id <> events.webhook_id;
+
+DROP INDEX IF EXISTS jobs_event_id_execution_date_webhook_id_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_event_id_execution_date_idx ON jobs (event_id, execution_date);
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS webhook_id;
+
+DROP TABLE IF EXISTS event_webhooks;
+
+ALTER TABLE webhooks
+    DROP COLUMN IF EXISTS channel_type;
@@ -0,0 +1,21 @@
+ALTER TABLE webhooks
+    ADD COLUMN IF NOT EXISTS channel_type text NOT NULL DEFAULT 'discord';
+
+CREATE TABLE IF NOT EXISTS event_webhooks (
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    webhook_id uuid NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+    PRIMARY KEY (event_id, webhook_id)
+);
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS webhook_id uuid NULL REFERENCES webhooks ON DELETE CASCADE;
+
+UPDATE jobs SET webhook_id = events.webhook_id FROM events WHERE events.id = jobs.event_id;
+
+-- The jobs of the events without webhook have nowhere to be sent.
+DELETE FROM jobs WHERE webhook_id IS NULL;
+
+ALTER TABLE jobs ALTER COLUMN webhook_id SET NOT NULL;
+
+DROP INDEX IF EXISTS jobs_event_id_execution_date_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_event_id_execution_date_webhook_id_idx ON jobs (event_id, execution_date, webhook_id);
@@ -0,0 +1,5 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS message_template;
+
+ALTER TABLE webhooks
+    DROP COLUMN IF EXISTS message_template;
@@ -0,0 +1,5 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS message_template jsonb NULL;
+
+ALTER TABLE webhooks
+    ADD COLUMN IF NOT EXISTS message_template jsonb NULL;
@@ -0,0 +1,10 @@
+DELETE FROM jobs WHERE reminder_id IS NOT NULL;
+
+DROP INDEX IF EXISTS jobs_occurrence_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_event_id_execution_date_webhook_id_idx ON jobs (event_id, execution_date, webhook_id);
+
+ALTER TABLE jobs
+    DROP COLUMN IF EXISTS reminder_id,
+    DROP COLUMN IF EXISTS occurrence_date;
+
+DROP TABLE IF EXISTS reminders;
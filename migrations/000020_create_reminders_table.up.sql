@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS reminders (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    time_offset text NOT NULL,
+    relative_to text NOT NULL DEFAULT 'start' CHECK (relative_to IN ('start', 'end')),
+    message_template jsonb NULL,
+    created_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reminders_event_id_idx ON reminders (event_id);
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS reminder_id uuid NULL REFERENCES reminders ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS occurrence_date timestamp(0) with time zone NULL;
+
+UPDATE jobs SET occurrence_date = execution_date;
+
+ALTER TABLE jobs ALTER COLUMN occurrence_date SET NOT NULL;
+
+-- An occurrence is announced once per webhook and reminder, the announcement
+-- of the events without reminders has no reminder_id.
+DROP INDEX IF EXISTS jobs_event_id_execution_date_webhook_id_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_occurrence_idx ON jobs (event_id, webhook_id, occurrence_date, COALESCE(reminder_id, '00000000-0000-0000-0000-000000000000'));
@@ -0,0 +1,5 @@
+ALTER TABLE events
+    DROP COLUMN IF EXISTS mentions;
+
+ALTER TABLE tags
+    DROP COLUMN IF EXISTS mentions;
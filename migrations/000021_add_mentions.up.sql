@@ -0,0 +1,5 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS mentions jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE tags
+    ADD COLUMN IF NOT EXISTS mentions jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS message_id text NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS scheduled_events (
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    occurrence_date timestamp(0) with time zone NOT NULL,
+    discord_id text NOT NULL,
+    created_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, occurrence_date)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS rsvps;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS capacity,
+    DROP COLUMN IF EXISTS rsvp;
@@ -0,0 +1,21 @@
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS rsvp boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS capacity integer NOT NULL DEFAULT 0 CHECK (capacity >= 0);
+
+-- An RSVP is either a user's or, for the Discord users without an account, a
+-- Discord user's. occurrence_date is the start the rule gives the occurrence,
+-- which does not change when the occurrence is moved.
+CREATE TABLE IF NOT EXISTS rsvps (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id uuid NOT NULL REFERENCES events ON DELETE CASCADE,
+    occurrence_date timestamp(0) with time zone NOT NULL,
+    user_id uuid NULL REFERENCES users ON DELETE CASCADE,
+    discord_user_id text NULL,
+    status text NOT NULL CHECK (status IN ('yes', 'no', 'maybe')),
+    created_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_date timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (discord_user_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS rsvps_user_idx ON rsvps (event_id, occurrence_date, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS rsvps_discord_user_idx ON rsvps (event_id, occurrence_date, discord_user_id) WHERE discord_user_id IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN IF NOT EXISTS source_cancelled boolean NOT NULL DEFAULT false;
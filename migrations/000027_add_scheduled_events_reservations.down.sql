@@ -0,0 +1,4 @@
+DELETE FROM scheduled_events WHERE discord_id IS NULL;
+
+ALTER TABLE scheduled_events
+    ALTER COLUMN discord_id SET NOT NULL;
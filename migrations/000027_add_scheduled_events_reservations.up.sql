@@ -0,0 +1,2 @@
+ALTER TABLE scheduled_events
+    ALTER COLUMN discord_id DROP NOT NULL;
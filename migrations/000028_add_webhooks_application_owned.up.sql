@@ -0,0 +1,2 @@
+ALTER TABLE webhooks
+    ADD COLUMN IF NOT EXISTS application_owned boolean NOT NULL DEFAULT false;
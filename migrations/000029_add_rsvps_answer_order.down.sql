@@ -0,0 +1,2 @@
+ALTER TABLE rsvps
+    DROP COLUMN IF EXISTS answer_order;
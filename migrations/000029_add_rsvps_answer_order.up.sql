@@ -0,0 +1,15 @@
+-- answer_order orders the answers, updated_date being too coarse to tell
+-- apart the answers given within the same second.
+ALTER TABLE rsvps
+    ADD COLUMN IF NOT EXISTS answer_order bigint NULL;
+
+UPDATE rsvps SET answer_order = answered.n
+FROM (SELECT id, row_number() OVER (ORDER BY updated_date, id) AS n FROM rsvps) answered
+WHERE answered.id = rsvps.id;
+
+CREATE SEQUENCE IF NOT EXISTS rsvps_answer_order_seq OWNED BY rsvps.answer_order;
+SELECT setval('rsvps_answer_order_seq', COALESCE(max(answer_order), 0) + 1, false) FROM rsvps;
+
+ALTER TABLE rsvps
+    ALTER COLUMN answer_order SET DEFAULT nextval('rsvps_answer_order_seq'),
+    ALTER COLUMN answer_order SET NOT NULL;